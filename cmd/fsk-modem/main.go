@@ -0,0 +1,577 @@
+// FSK modem command-line tool
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+	"github.com/gleicon/go-fsk/fsk/framing"
+	"github.com/gleicon/go-fsk/fsk/kcs"
+	"github.com/gleicon/go-fsk/fsk/realtime"
+	"github.com/gleicon/go-fsk/fsk/utils"
+)
+
+// Set at build time through -ldflags (see Makefile)
+var (
+	version   = "dev"
+	buildTime = "unknown"
+)
+
+func main() {
+	mode := flag.String("mode", "", "Mode: 'tx' for transmit, 'rx' for receive, 'rtx' for real-time transmit, 'rrx' for real-time receive, 'chat' for duplex chat, 'kcs-tx'/'kcs-rx' for cassette tapes")
+	msg := flag.String("msg", "", "Message to transmit")
+	file := flag.String("file", "", "File to transmit or save received data")
+	input := flag.String("input", "input.wav", "Input WAV file for receive mode")
+	channel := flag.Int("channel", utils.MixChannels, "Input WAV channel to decode, counting from 0 (-1 mixes all channels)")
+	output := flag.String("output", "output.wav", "Output WAV file for transmit mode")
+	freq := flag.String("freq", "1000,200", "Base frequency and spacing in Hz (base,spacing)")
+	order := flag.Int("order", 2, "FSK order (2^n symbols, typically 2-4)")
+	tones := flag.String("tones", "", "Explicit tone list in Hz, overrides -freq and -order (e.g. 1200,2400)")
+	baud := flag.Float64("baud", 100, "Symbol rate (symbols per second)")
+	detector := flag.String("detector", core.DefaultConfig().Detector.String(), "Tone detector: correlation, goertzel, sliding-dft, fft or discriminator (needed for tones closer than the baud rate)")
+	duration := flag.Float64("duration", 5, "Receive duration in seconds (real-time rx mode)")
+	test := flag.Bool("test", false, "Run test mode (encode then decode)")
+	halfDuplex := flag.Bool("half-duplex", false, "Mute the receiver while transmitting (chat mode)")
+	tape := flag.String("tape", "kcs", "Cassette format for kcs modes: 'kcs' (300 baud), 'cuts' (1200 baud, BBC Micro) or 'msx' (1200 baud, 2 stop bits); -baud and -tones space,mark override it")
+	zeroCrossing := flag.Bool("zero-crossing", false, "Decode cassette tapes by timing zero crossings instead of measuring the tones (kcs-rx mode)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "fsk-modem %s (built %s)\n\n", version, buildTime)
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Cassette tapes have their own format and ignore the modem flags
+	if *mode == "kcs-tx" || *mode == "kcs-rx" {
+		config, err := tapeConfig(*tape)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *zeroCrossing {
+			config.Method = kcs.MethodZeroCrossing
+		}
+		if err := overrideTape(&config, *baud, *tones); err != nil {
+			log.Fatalf("Invalid tape format: %v", err)
+		}
+
+		if *mode == "kcs-rx" {
+			runReceiveTape(config, *input, *channel, *file)
+			return
+		}
+		data, err := payload(*msg, *file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runTransmitTape(config, data, *output)
+		return
+	}
+
+	baseFreq, freqSpacing, err := parseFreq(*freq)
+	if err != nil {
+		log.Fatalf("Invalid -freq %q: %v", *freq, err)
+	}
+
+	config := core.DefaultConfig()
+	config.BaseFreq = baseFreq
+	config.FreqSpacing = freqSpacing
+	config.Order = *order
+	config.BaudRate = *baud
+	// Recordings and live captures rarely start on a symbol boundary
+	config.TimingRecovery = true
+	if config.Detector, err = parseDetector(*detector); err != nil {
+		log.Fatalf("Invalid -detector %q: %v", *detector, err)
+	}
+
+	if *tones != "" {
+		config.Tones, err = parseTones(*tones)
+		if err != nil {
+			log.Fatalf("Invalid -tones %q: %v", *tones, err)
+		}
+	}
+
+	modem, err := core.NewWithError(config)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if *test {
+		runTest(modem, *msg)
+		return
+	}
+
+	switch *mode {
+	case "tx":
+		data, err := payload(*msg, *file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runTransmitFile(modem, data, *output)
+	case "rx":
+		runReceiveFile(modem, modemFlagsSet(), *input, *channel, *file)
+	case "rtx":
+		data, err := payload(*msg, *file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runTransmitLive(modem, data)
+	case "rrx":
+		runReceiveLive(modem, *duration, *file)
+	case "chat":
+		runChat(modem, *halfDuplex)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// modemFlagsSet reports whether any flag describing the modem was given on
+// the command line.
+func modemFlagsSet() bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "freq", "order", "tones", "baud":
+			set = true
+		}
+	})
+	return set
+}
+
+// tapeConfig returns the cassette format named by -tape.
+func tapeConfig(name string) (kcs.Config, error) {
+	switch name {
+	case "kcs":
+		return kcs.KCSConfig(), nil
+	case "cuts", "bbc":
+		return kcs.CUTSConfig(), nil
+	case "msx":
+		config := kcs.CUTSConfig()
+		config.StopBits = 2
+		return config, nil
+	}
+	return kcs.Config{}, fmt.Errorf("unknown tape format %q: use kcs, cuts or msx", name)
+}
+
+// overrideTape applies -baud and a two tone -tones list (space,mark) to a
+// cassette format, when given on the command line.
+func overrideTape(config *kcs.Config, baud float64, tones string) error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "baud":
+			config.BaudRate = baud
+		case "tones":
+			var list []float64
+			if list, err = parseTones(tones); err == nil && len(list) != 2 {
+				err = fmt.Errorf("expected space,mark")
+			}
+			if err != nil {
+				err = fmt.Errorf("invalid -tones %q: %v", tones, err)
+				return
+			}
+			config.SpaceFreq, config.MarkFreq = list[0], list[1]
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return config.Validate()
+}
+
+// parseFreq parses a "base,spacing" pair in Hz.
+func parseFreq(value string) (float64, float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected base,spacing")
+	}
+
+	base, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid base frequency: %v", err)
+	}
+
+	spacing, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid frequency spacing: %v", err)
+	}
+
+	return base, spacing, nil
+}
+
+// parseTones parses a comma separated list of frequencies in Hz.
+func parseTones(value string) ([]float64, error) {
+	var tones []float64
+	for _, part := range strings.Split(value, ",") {
+		tone, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tone: %v", err)
+		}
+		tones = append(tones, tone)
+	}
+	return tones, nil
+}
+
+// parseDetector returns the tone detector with the given name.
+func parseDetector(name string) (core.DetectorType, error) {
+	for detector := core.DetectorCorrelation; detector <= core.DetectorDiscriminator; detector++ {
+		if detector.String() == name {
+			return detector, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown detector")
+}
+
+// payload returns the data to transmit, taken from -file if set, otherwise -msg.
+func payload(msg, file string) ([]byte, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		return data, nil
+	}
+
+	if msg == "" {
+		return nil, fmt.Errorf("nothing to transmit: use -msg or -file")
+	}
+	return []byte(msg), nil
+}
+
+// deliver writes received data to file, or prints it when no file is given.
+func deliver(data []byte, file string) {
+	if file == "" {
+		fmt.Printf("Decoded: %s\n", string(data))
+		return
+	}
+
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", file, err)
+	}
+	fmt.Printf("Saved %d bytes to %s\n", len(data), file)
+}
+
+func printConfig(modem *core.Modem) {
+	config := modem.Config()
+	frequencies := modem.Frequencies()
+
+	fmt.Printf("FSK: %d symbols, %.0f-%.0f Hz, %.0f baud, %d Hz sample rate\n",
+		len(frequencies), frequencies[0], frequencies[len(frequencies)-1],
+		config.BaudRate, config.SampleRate)
+}
+
+func printFormat(format utils.WAVFormat) {
+	kind := "integer"
+	if format.Float {
+		kind = "float"
+	}
+	fmt.Printf("WAV: %d Hz, %d channels, %d-bit %s\n",
+		format.SampleRate, format.Channels, format.BitsPerSample, kind)
+}
+
+func printTape(config kcs.Config) {
+	parity := "N"
+	switch config.Parity {
+	case kcs.ParityEven:
+		parity = "E"
+	case kcs.ParityOdd:
+		parity = "O"
+	}
+	fmt.Printf("Tape: %.0f baud, %.0f/%.0f Hz, %d%s%d, %s decoding, %d Hz sample rate\n",
+		config.BaudRate, config.SpaceFreq, config.MarkFreq,
+		config.DataBits, parity, config.StopBits, config.Method, config.SampleRate)
+}
+
+func runTest(modem *core.Modem, msg string) {
+	if msg == "" {
+		msg = "Hello FSK World!"
+	}
+	printConfig(modem)
+
+	signal := modem.Encode([]byte(msg))
+	duration := float64(len(signal)) / float64(modem.Config().SampleRate)
+	fmt.Printf("Original: %s\n", msg)
+	fmt.Printf("Encoded to %d samples (%.2f seconds)\n", len(signal), duration)
+
+	decoded := string(modem.Decode(signal))
+	fmt.Printf("Decoded:  %s\n", decoded)
+
+	if decoded != msg {
+		fmt.Println("❌ Test failed")
+		os.Exit(1)
+	}
+	fmt.Println("✅ Test passed")
+}
+
+func runTransmitFile(modem *core.Modem, data []byte, output string) {
+	printConfig(modem)
+
+	signal := modem.Encode(data)
+	if err := utils.WriteWAVFile(output, signal, modem.Config()); err != nil {
+		log.Fatalf("Failed to write %s: %v", output, err)
+	}
+
+	duration := float64(len(signal)) / float64(modem.Config().SampleRate)
+	fmt.Printf("Encoded %d bytes to %s (%.2f seconds)\n", len(data), output, duration)
+}
+
+// runReceiveFile decodes a WAV file. Unless the modem was described on the
+// command line, the configuration embedded in the file is used.
+func runReceiveFile(modem *core.Modem, explicit bool, input string, channel int, file string) {
+	wav, err := os.Open(input)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+	defer wav.Close()
+
+	reader, err := utils.NewWAVReader(wav)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+	if err := reader.SetChannel(channel); err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+
+	metadata := reader.Metadata()
+	switch {
+	case metadata != nil && explicit:
+		fmt.Println("Ignoring the modem configuration embedded in the file")
+		metadata = nil
+	case metadata != nil:
+		fmt.Println("Using the modem configuration embedded in the file")
+		modem, err = core.NewWithError(metadata.Modem)
+		if err != nil {
+			log.Fatalf("Invalid configuration in %s: %v", input, err)
+		}
+	}
+	printConfig(modem)
+
+	signal, err := reader.ReadAll()
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		log.Printf("Warning: %s is truncated", input)
+	} else if err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+	format := reader.Format()
+	printFormat(format)
+
+	if sampleRate := modem.Config().SampleRate; format.SampleRate != sampleRate {
+		fmt.Printf("Resampling from %d Hz to %d Hz\n", format.SampleRate, sampleRate)
+		signal, err = utils.Resample(signal, format.SampleRate, sampleRate)
+		if err != nil {
+			log.Fatalf("Failed to resample %s: %v", input, err)
+		}
+	}
+
+	if metadata == nil || metadata.Framing == nil {
+		deliver(modem.Decode(signal), file)
+		return
+	}
+
+	// Framed signals carry their own length and checksum
+	var data []byte
+	frames := framing.NewDetector(modem, *metadata.Framing).Write(signal)
+	for _, payload := range frames {
+		data = append(data, payload...)
+	}
+	fmt.Printf("Found %d frames\n", len(frames))
+	deliver(data, file)
+}
+
+// runTransmitTape writes data to a WAV file as a cassette recording.
+func runTransmitTape(config kcs.Config, data []byte, output string) {
+	printTape(config)
+
+	signal, err := kcs.Encode(data, config)
+	if err != nil {
+		log.Fatalf("Invalid tape format: %v", err)
+	}
+
+	wav, err := os.Create(output)
+	if err != nil {
+		log.Fatalf("Failed to write %s: %v", output, err)
+	}
+	writer := utils.NewWAVWriter(wav, config.SampleRate)
+	err = writer.Write(signal)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := wav.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Failed to write %s: %v", output, err)
+	}
+
+	duration := float64(len(signal)) / float64(config.SampleRate)
+	fmt.Printf("Encoded %d bytes to %s (%.2f seconds)\n", len(data), output, duration)
+}
+
+// runReceiveTape decodes a cassette recording at the WAV file's own sample
+// rate, streaming it through the decoder.
+func runReceiveTape(config kcs.Config, input string, channel int, file string) {
+	wav, err := os.Open(input)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+	defer wav.Close()
+
+	reader, err := utils.NewWAVReader(wav)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+	if err := reader.SetChannel(channel); err != nil {
+		log.Fatalf("Failed to read %s: %v", input, err)
+	}
+	format := reader.Format()
+	printFormat(format)
+
+	config.SampleRate = format.SampleRate
+	printTape(config)
+	decoder, err := kcs.NewDecoder(config)
+	if err != nil {
+		log.Fatalf("Invalid tape format: %v", err)
+	}
+
+	var data []byte
+	samples := make([]float32, 8192)
+	for {
+		n, err := reader.Read(samples)
+		data = append(data, decoder.Write(samples[:n])...)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("Warning: %s is truncated", input)
+			break
+		}
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", input, err)
+		}
+	}
+
+	if errs := decoder.Errors(); errs > 0 {
+		fmt.Printf("Dropped %d characters with framing or parity errors\n", errs)
+	}
+	deliver(data, file)
+}
+
+func runTransmitLive(modem *core.Modem, data []byte) {
+	printConfig(modem)
+
+	transmitter, err := realtime.NewTransmitter(modem)
+	if err != nil {
+		log.Fatalf("Failed to create transmitter: %v", err)
+	}
+	defer transmitter.Close()
+
+	fmt.Printf("Transmitting %d bytes...\n", len(data))
+	if err := transmitter.Transmit(data); err != nil {
+		log.Fatalf("Transmission failed: %v", err)
+	}
+	fmt.Println("Transmission complete")
+}
+
+func runReceiveLive(modem *core.Modem, duration float64, file string) {
+	printConfig(modem)
+
+	received := make(chan []byte, 100)
+	receiver, err := realtime.NewReceiver(modem, func(data []byte) {
+		chunk := append([]byte(nil), data...)
+		select {
+		case received <- chunk:
+		default:
+		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to create receiver: %v", err)
+	}
+	defer receiver.Close()
+
+	if err := receiver.Start(); err != nil {
+		log.Fatalf("Failed to start receiver: %v", err)
+	}
+	fmt.Printf("Listening for %.1f seconds...\n", duration)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	timeout := time.After(time.Duration(duration * float64(time.Second)))
+
+	var data []byte
+	for {
+		select {
+		case chunk := <-received:
+			if file == "" {
+				fmt.Print(string(chunk))
+			}
+			data = append(data, chunk...)
+		case <-interrupt:
+			receiver.Stop()
+			reportOverruns(receiver)
+			finishReceive(data, file)
+			return
+		case <-timeout:
+			receiver.Stop()
+			reportOverruns(receiver)
+			finishReceive(data, file)
+			return
+		}
+	}
+}
+
+func reportOverruns(receiver *realtime.Receiver) {
+	if overruns := receiver.Overruns(); overruns > 0 {
+		fmt.Printf("Warning: %d capture overruns, %d samples dropped\n", overruns, receiver.DroppedSamples())
+	}
+}
+
+func finishReceive(data []byte, file string) {
+	if file == "" {
+		fmt.Printf("\nReceived %d bytes\n", len(data))
+		return
+	}
+	deliver(data, file)
+}
+
+func runChat(modem *core.Modem, halfDuplex bool) {
+	printConfig(modem)
+
+	session, err := realtime.NewChatSession(modem)
+	if err != nil {
+		log.Fatalf("Failed to create chat session: %v", err)
+	}
+	defer session.Close()
+	session.SetHalfDuplex(halfDuplex, realtime.DefaultMuteTail)
+
+	if err := session.Start(); err != nil {
+		log.Fatalf("Failed to start chat session: %v", err)
+	}
+
+	go func() {
+		for msg := range session.ReceiveMessages() {
+			fmt.Printf("\r< %s\n> ", msg)
+		}
+	}()
+
+	fmt.Println("Type a message and press enter to send (Ctrl+D to quit)")
+	fmt.Print("> ")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			session.SendMessage(line)
+		}
+		fmt.Print("> ")
+	}
+}
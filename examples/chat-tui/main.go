@@ -45,6 +45,10 @@ var (
 			Foreground(lipgloss.Color("#626262"))
 )
 
+// sampleRate of the shared audio streams, high enough for every
+// predefined channel
+const sampleRate = 96000
+
 // Message represents a chat message
 type Message struct {
 	Channel   int
@@ -52,6 +56,7 @@ type Message struct {
 	Content   string
 	Timestamp time.Time
 	IsOwn     bool
+	ID        uint32 // Sender's message ID, zero for local messages
 }
 
 // Global message channel for FSK callbacks to communicate with Bubble Tea
@@ -76,8 +81,7 @@ type Model struct {
 type tickMsg struct{}
 type messageReceivedMsg struct {
 	channelID int
-	username  string
-	message   string
+	message   realtime.ChatMessage
 }
 
 func tickEvery() tea.Cmd {
@@ -131,18 +135,27 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Initialize chat system once we have the window
 		if m.chat == nil {
-			m.chat = realtime.NewMultiChannelChat(m.username, func(channelID int, username, message string) {
+			m.chat = realtime.NewMultiChannelChat(m.username, nil)
+			m.chat.OnMessage(func(channelID int, msg realtime.ChatMessage) {
 				// Send received message to the global channel
 				select {
 				case messageChan <- messageReceivedMsg{
 					channelID: channelID,
-					username:  username,
-					message:   message,
+					message:   msg,
 				}:
 				default:
 					// Channel full, drop message
 				}
 			})
+			if err := m.chat.SetSampleRate(sampleRate); err != nil {
+				m.messages = append(m.messages, Message{
+					Channel:   0,
+					Username:  "System",
+					Content:   fmt.Sprintf("Failed to set the sample rate: %v", err),
+					Timestamp: time.Now(),
+					IsOwn:     false,
+				})
+			}
 		}
 		return m, nil
 
@@ -160,13 +173,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Batch(tickEvery(), listenForMessages())
 
 	case messageReceivedMsg:
-		if msg.channelID != 0 { // Only add non-nil messages
+		if msg.channelID != 0 && !m.seen(msg.message) { // Only add non-nil messages
 			m.messages = append(m.messages, Message{
 				Channel:   msg.channelID,
-				Username:  msg.username,
-				Content:   msg.message,
-				Timestamp: time.Now(),
+				Username:  msg.message.Sender,
+				Content:   msg.message.Body,
+				Timestamp: msg.message.Time,
 				IsOwn:     false,
+				ID:        msg.message.ID,
 			})
 		}
 		return m, listenForMessages()
@@ -190,25 +204,15 @@ func (m Model) updateChannelSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "enter", " ":
 		// Join selected channel
 		if m.chat != nil {
-			channelConfig := m.channels[m.currentChan-1]
-			err := m.chat.JoinChannel(channelConfig, 2, 100)
-			if err != nil {
-				m.messages = append(m.messages, Message{
-					Channel:   0,
-					Username:  "System",
-					Content:   fmt.Sprintf("Failed to join %s: %v", channelConfig.Name, err),
-					Timestamp: time.Now(),
-					IsOwn:     false,
-				})
-			} else {
-				m.activeChans = append(m.activeChans, channelConfig.ID)
-				m.messages = append(m.messages, Message{
-					Channel:   0,
-					Username:  "System",
-					Content:   fmt.Sprintf("Joined %s", channelConfig.Name),
-					Timestamp: time.Now(),
-					IsOwn:     false,
-				})
+			m.join(m.channels[m.currentChan-1])
+		}
+	case "a":
+		// Join every channel not joined yet
+		if m.chat != nil {
+			for _, channelConfig := range m.channels {
+				if !m.isActive(channelConfig.ID) {
+					m.join(channelConfig)
+				}
 			}
 		}
 	case "l":
@@ -251,6 +255,53 @@ func (m Model) updateChannelSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// join joins a channel and reports the outcome as a system message
+func (m *Model) join(channelConfig realtime.ChannelConfig) {
+	err := m.chat.JoinChannel(channelConfig, 2, 100)
+	if err != nil {
+		m.messages = append(m.messages, Message{
+			Channel:   0,
+			Username:  "System",
+			Content:   fmt.Sprintf("Failed to join %s: %v", channelConfig.Name, err),
+			Timestamp: time.Now(),
+			IsOwn:     false,
+		})
+		return
+	}
+
+	m.activeChans = append(m.activeChans, channelConfig.ID)
+	m.messages = append(m.messages, Message{
+		Channel:   0,
+		Username:  "System",
+		Content:   fmt.Sprintf("Joined %s", channelConfig.Name),
+		Timestamp: time.Now(),
+		IsOwn:     false,
+	})
+}
+
+// seen reports whether a broadcast was already received on another channel
+func (m *Model) seen(msg realtime.ChatMessage) bool {
+	if msg.ID == 0 {
+		return false
+	}
+	for _, existing := range m.messages {
+		if existing.ID == msg.ID && existing.Username == msg.Sender {
+			return true
+		}
+	}
+	return false
+}
+
+// isActive reports whether a channel has been joined
+func (m *Model) isActive(channelID int) bool {
+	for _, activeID := range m.activeChans {
+		if activeID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
 func (m Model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "esc":
@@ -352,7 +403,7 @@ func (m Model) viewChannelSelect() string {
 	}
 
 	b.WriteString("\n")
-	b.WriteString(helpStyle.Render("↑/↓: navigate • enter: join • l: leave • c: chat mode • h: help • q: quit"))
+	b.WriteString(helpStyle.Render("↑/↓: navigate • enter: join • a: join all • l: leave • c: chat mode • h: help • q: quit"))
 
 	// Show recent messages
 	if len(m.messages) > 0 {
@@ -465,6 +516,7 @@ func (m Model) viewHelp() string {
 	b.WriteString("Channel Selection:\n")
 	b.WriteString("  ↑/↓ or j/k: Navigate channels\n")
 	b.WriteString("  enter/space: Join selected channel\n")
+	b.WriteString("  a: Join all channels\n")
 	b.WriteString("  l: Leave selected channel\n")
 	b.WriteString("  c: Switch to chat mode\n")
 	b.WriteString("  h or ?: Show this help\n")
@@ -501,4 +553,4 @@ func main() {
 	if model.chat != nil {
 		model.chat.Close()
 	}
-}
+}
\ No newline at end of file
@@ -0,0 +1,199 @@
+package core
+
+import "math"
+
+// Carrier detection defaults.
+const (
+	DefaultSquelch           = 0.01 // RMS level a carrier must reach (-40 dBFS)
+	DefaultSquelchHysteresis = 6.0  // dB below the squelch at which a carrier falls
+	DefaultCarrierOpen       = 0.6  // Tone purity at which a carrier rises
+	DefaultCarrierClose      = 0.4  // Tone purity below which a carrier falls
+	DefaultCarrierHang       = 2    // Weak symbols tolerated before a carrier falls
+)
+
+// CarrierConfig holds the thresholds of a CarrierDetector. A carrier rises
+// on a symbol-long block that is both loud enough and pure enough, and
+// falls after more than Hang consecutive blocks that are too quiet or too
+// impure. The gap between the rising and falling thresholds keeps the
+// carrier from chattering on a marginal signal.
+//
+// The zero value never squelches: the carrier rises on the first block and
+// stays up.
+type CarrierConfig struct {
+	Squelch           float64 // Minimum RMS level of a rising carrier
+	SquelchHysteresis float64 // dB below Squelch at which the carrier falls
+	Open              float64 // Minimum tone purity of a rising carrier
+	Close             float64 // Tone purity below which the carrier falls
+	Hang              int     // Weak blocks tolerated before the carrier falls
+}
+
+// DefaultCarrierConfig returns thresholds suited to signals well above the
+// noise floor of a sound card.
+func DefaultCarrierConfig() CarrierConfig {
+	return CarrierConfig{
+		Squelch:           DefaultSquelch,
+		SquelchHysteresis: DefaultSquelchHysteresis,
+		Open:              DefaultCarrierOpen,
+		Close:             DefaultCarrierClose,
+		Hang:              DefaultCarrierHang,
+	}
+}
+
+// Validate checks that the thresholds are consistent, returning a
+// *ConfigError for the first invalid field it finds.
+func (c CarrierConfig) Validate() error {
+	if c.Squelch < 0 || math.IsNaN(c.Squelch) {
+		return &ConfigError{"Squelch", c.Squelch, "must not be negative", ErrInvalidThreshold}
+	}
+	if c.SquelchHysteresis < 0 || math.IsNaN(c.SquelchHysteresis) {
+		return &ConfigError{"SquelchHysteresis", c.SquelchHysteresis, "must not be negative", ErrInvalidThreshold}
+	}
+	if c.Close < 0 || math.IsNaN(c.Close) {
+		return &ConfigError{"Close", c.Close, "must not be negative", ErrInvalidThreshold}
+	}
+	if !(c.Open >= c.Close) {
+		return &ConfigError{"Open", c.Open, "must not be below Close", ErrInvalidThreshold}
+	}
+	if c.Hang < 0 {
+		return &ConfigError{"Hang", c.Hang, "must not be negative", ErrInvalidThreshold}
+	}
+	return nil
+}
+
+// floor returns the level below which a present carrier counts as weak.
+func (c CarrierConfig) floor() float64 {
+	return c.Squelch * math.Pow(10, -c.SquelchHysteresis/20)
+}
+
+// CarrierEvent reports a carrier rising or falling. Positions count samples
+// from the start of the stream. A falling carrier is only recognised Hang+1
+// blocks after its last strong block, so its Offset lies before Detected.
+type CarrierEvent struct {
+	Present  bool    // True when the carrier rose, false when it fell
+	Offset   int     // End of the first strong block, or of the last one when falling
+	Detected int     // Position at which the detector decided
+	Level    float64 // RMS level of the deciding block
+	Purity   float64 // Tone purity of the deciding block
+}
+
+// CarrierDetector watches a sample stream for the tones of a modem. It
+// measures each symbol-long block for level and tone purity, the share of
+// its amplitude that sits on the tones, and reports when a carrier rises
+// and falls.
+//
+// It is not safe for concurrent use.
+type CarrierDetector struct {
+	config   CarrierConfig
+	floor    float64      // Level below which an open carrier counts as weak
+	detector ToneDetector // Goertzel detector used to measure tone purity
+	levels   []float64    // Scratch buffer for detector
+	block    []float32    // Samples of the block being filled
+	size     int          // Samples per block
+	position int          // Samples processed so far
+	present  bool
+	weak     int // Consecutive weak blocks while present
+	strong   int // End of the last strong block while present
+	level    float64
+	purity   float64
+}
+
+// NewCarrierDetector creates a detector for the tones of modem.
+func NewCarrierDetector(modem *Modem, config CarrierConfig) *CarrierDetector {
+	return &CarrierDetector{
+		config:   config,
+		floor:    config.floor(),
+		detector: NewToneDetector(DetectorGoertzel, modem.frequencies, modem.config.SampleRate),
+		levels:   make([]float64, len(modem.frequencies)),
+		size:     modem.symbolPeriod,
+	}
+}
+
+// Process measures samples and appends an event to events for every change
+// of the carrier, returning the extended slice.
+func (d *CarrierDetector) Process(samples []float32, events []CarrierEvent) []CarrierEvent {
+	for len(samples) > 0 {
+		n := d.size - len(d.block)
+		if n > len(samples) {
+			n = len(samples)
+		}
+		d.block = append(d.block, samples[:n]...)
+		samples = samples[n:]
+		d.position += n
+
+		if len(d.block) < d.size {
+			break
+		}
+		if event, changed := d.measure(); changed {
+			events = append(events, event)
+		}
+		d.block = d.block[:0]
+	}
+	return events
+}
+
+// measure classifies the full block and updates the carrier state.
+func (d *CarrierDetector) measure() (CarrierEvent, bool) {
+	d.purity, d.level = measurePurity(d.detector, d.levels, d.block)
+	event := CarrierEvent{Offset: d.position, Detected: d.position, Level: d.level, Purity: d.purity}
+
+	if !d.present {
+		if d.level >= d.config.Squelch && d.purity >= d.config.Open {
+			d.present = true
+			d.weak = 0
+			d.strong = d.position
+			event.Present = true
+			return event, true
+		}
+		return event, false
+	}
+
+	if d.level < d.floor || d.purity < d.config.Close {
+		d.weak++
+		if d.weak > d.config.Hang {
+			d.present = false
+			event.Offset = d.strong
+			return event, true
+		}
+	} else {
+		d.weak = 0
+		d.strong = d.position
+	}
+	return event, false
+}
+
+// Present reports whether a carrier is up.
+func (d *CarrierDetector) Present() bool {
+	return d.present
+}
+
+// Level returns the RMS level of the last complete block.
+func (d *CarrierDetector) Level() float64 {
+	return d.level
+}
+
+// Purity returns the tone purity of the last complete block.
+func (d *CarrierDetector) Purity() float64 {
+	return d.purity
+}
+
+// Config returns the detector thresholds.
+func (d *CarrierDetector) Config() CarrierConfig {
+	return d.config
+}
+
+// BlockSize returns the number of samples per measured block.
+func (d *CarrierDetector) BlockSize() int {
+	return d.size
+}
+
+// Reset drops the carrier and any partial block. Stream positions start
+// again from zero.
+func (d *CarrierDetector) Reset() {
+	d.block = d.block[:0]
+	d.position = 0
+	d.present = false
+	d.weak = 0
+	d.strong = 0
+	d.level = 0
+	d.purity = 0
+}
\ No newline at end of file
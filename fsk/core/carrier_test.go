@@ -0,0 +1,82 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCarrierDetector(t *testing.T) {
+	modem := New(DefaultConfig())
+	period := modem.SymbolPeriod()
+	signal := modem.Encode([]byte("carrier"))
+	rng := rand.New(rand.NewSource(1))
+	noise := func(n int, deviation float64) []float32 {
+		samples := make([]float32, n)
+		for i := range samples {
+			samples[i] = float32(rng.NormFloat64() * deviation)
+		}
+		return samples
+	}
+	quiet := make([]float32, len(signal))
+	for i, sample := range signal {
+		quiet[i] = sample * 0.005
+	}
+
+	tests := []struct {
+		name    string
+		capture []float32
+		start   int // Signal position in the capture, -1 for no carrier
+	}{
+		{"silence", make([]float32, 10*period), -1},
+		{"loud noise", noise(10*period, 0.3), -1},
+		{"below squelch", append(make([]float32, 4*period), quiet...), -1},
+		{"signal", append(append(make([]float32, 4*period), signal...), make([]float32, 10*period)...), 4 * period},
+		{"signal in noise", append(append(noise(4*period, 0.001), signal...), noise(10*period, 0.001)...), 4 * period},
+	}
+
+	for _, tt := range tests {
+		detector := NewCarrierDetector(modem, DefaultCarrierConfig())
+		events := detector.Process(tt.capture, nil)
+
+		if tt.start < 0 {
+			if len(events) != 0 || detector.Present() {
+				t.Errorf("%s: events %+v, want none", tt.name, events)
+			}
+			continue
+		}
+		if len(events) != 2 || !events[0].Present || events[1].Present {
+			t.Fatalf("%s: events %+v, want a rise and a fall", tt.name, events)
+		}
+		end := tt.start + len(signal)
+		if rise := events[0].Offset; rise < tt.start || rise > tt.start+period {
+			t.Errorf("%s: carrier rose at %d, want the first symbol after %d", tt.name, rise, tt.start)
+		}
+		if fall := events[1].Offset; fall < end-period || fall > end {
+			t.Errorf("%s: carrier fell at %d, want the last symbol before %d", tt.name, fall, end)
+		}
+		if events[1].Detected <= events[1].Offset {
+			t.Errorf("%s: fall detected at %d, before its offset %d", tt.name, events[1].Detected, events[1].Offset)
+		}
+	}
+}
+
+func TestCarrierConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config CarrierConfig
+		valid  bool
+	}{
+		{"default", DefaultCarrierConfig(), true},
+		{"zero", CarrierConfig{}, true},
+		{"negative squelch", CarrierConfig{Squelch: -1}, false},
+		{"negative hysteresis", CarrierConfig{SquelchHysteresis: -1}, false},
+		{"open below close", CarrierConfig{Open: 0.3, Close: 0.6}, false},
+		{"negative hang", CarrierConfig{Hang: -1}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,210 @@
+package core
+
+import "math"
+
+const (
+	channelizerTaps = 2047 // Filter length; the transition band is 129 Hz wide at 48 kHz
+	channelizerSize = 8192 // FFT size of the overlap-save blocks
+)
+
+// Band is one output of a Channelizer.
+type Band struct {
+	Low, High float64   // Band edges in Hz
+	Isolated  bool      // Output everything but the other bands
+	re, im    []float64 // Frequency response of the band-pass filter
+}
+
+// Channelizer splits one sample stream into per-channel streams with a
+// bank of linear-phase FIR band-pass filters. A plain band passes only its
+// own frequencies. An isolated band passes the whole input except the
+// other bands, removing neighbouring channels while leaving noise
+// broadband, which matters to detectors that judge a signal by the share
+// of its energy on the tones.
+//
+// Filtering is done by overlap-save FFT convolution: every block of input
+// is transformed once and shared by all bands, so each band only costs an
+// inverse transform. Output is produced in whole blocks and lags the input
+// by the block length plus Delay samples.
+//
+// It is not safe for concurrent use.
+type Channelizer struct {
+	sampleRate int
+	taps       int
+	hop        int       // New samples per block
+	input      []float64 // Last taps-1 samples followed by the block being filled
+	fill       int       // Samples of input in use
+	re, im     []float64 // Spectrum of the current block
+	bre, bim   []float64 // Scratch buffers for the band outputs
+	dre, dim   []float64 // Response of a pure delay of Delay samples
+	sre, sim   []float64 // Sum of the responses of all bands
+	out        []float32
+	bands      []*Band
+}
+
+// NewChannelizer creates a channelizer for audio at sampleRate with no
+// bands.
+func NewChannelizer(sampleRate int) *Channelizer {
+	taps := channelizerTaps
+	c := &Channelizer{
+		sampleRate: sampleRate,
+		taps:       taps,
+		hop:        channelizerSize - taps + 1,
+		input:      make([]float64, channelizerSize),
+		fill:       taps - 1,
+		re:         make([]float64, channelizerSize),
+		im:         make([]float64, channelizerSize),
+		bre:        make([]float64, channelizerSize),
+		bim:        make([]float64, channelizerSize),
+		sre:        make([]float64, channelizerSize),
+		sim:        make([]float64, channelizerSize),
+		out:        make([]float32, channelizerSize-taps+1),
+	}
+	c.dre = make([]float64, channelizerSize)
+	c.dim = make([]float64, channelizerSize)
+	c.dre[c.Delay()] = 1
+	fft(c.dre, c.dim)
+	return c
+}
+
+// Add creates a band passing low to high Hz. Edges at or beyond zero and
+// the Nyquist frequency leave that side open. The band produces output
+// from the next complete block.
+func (c *Channelizer) Add(low, high float64) *Band {
+	return c.add(low, high, false)
+}
+
+// AddIsolated creates a band from low to high Hz that outputs the input
+// with every other band removed. Bands added or removed later change what
+// it removes from the next complete block.
+func (c *Channelizer) AddIsolated(low, high float64) *Band {
+	return c.add(low, high, true)
+}
+
+func (c *Channelizer) add(low, high float64, isolated bool) *Band {
+	band := &Band{
+		Low:      low,
+		High:     high,
+		Isolated: isolated,
+		re:       make([]float64, channelizerSize),
+		im:       make([]float64, channelizerSize),
+	}
+
+	// Blackman-windowed sinc band-pass, zero padded to the block size
+	fs := float64(c.sampleRate)
+	f1 := math.Max(low, 0) / fs
+	f2 := math.Min(high, fs/2) / fs
+	centre := float64(c.taps-1) / 2
+	for n := 0; n < c.taps; n++ {
+		t := float64(n) - centre
+		x := 2 * math.Pi * float64(n) / float64(c.taps-1)
+		window := 0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x)
+		band.re[n] = window * (2*f2*sinc(2*f2*t) - 2*f1*sinc(2*f1*t))
+	}
+	fft(band.re, band.im)
+
+	c.bands = append(c.bands, band)
+	c.accumulate(band, 1)
+	return band
+}
+
+// Remove deletes band from the channelizer.
+func (c *Channelizer) Remove(band *Band) {
+	for i, b := range c.bands {
+		if b == band {
+			c.bands = append(c.bands[:i], c.bands[i+1:]...)
+			c.accumulate(band, -1)
+			return
+		}
+	}
+}
+
+// accumulate adds sign times the response of band to the sum of all bands.
+func (c *Channelizer) accumulate(band *Band, sign float64) {
+	for k := range c.sre {
+		c.sre[k] += sign * band.re[k]
+		c.sim[k] += sign * band.im[k]
+	}
+}
+
+// Bands returns the number of bands.
+func (c *Channelizer) Bands() int {
+	return len(c.bands)
+}
+
+// Delay returns the group delay of the filters in samples.
+func (c *Channelizer) Delay() int {
+	return (c.taps - 1) / 2
+}
+
+// Process filters samples and calls emit with the output of every band for
+// each block completed. The slice passed to emit is reused afterwards.
+func (c *Channelizer) Process(samples []float32, emit func(band *Band, samples []float32)) {
+	for len(samples) > 0 {
+		n := len(c.input) - c.fill
+		if n > len(samples) {
+			n = len(samples)
+		}
+		for i := 0; i < n; i++ {
+			c.input[c.fill+i] = float64(samples[i])
+		}
+		c.fill += n
+		samples = samples[n:]
+
+		if c.fill == len(c.input) {
+			c.filter(emit)
+			copy(c.input, c.input[c.hop:])
+			c.fill = c.taps - 1
+		}
+	}
+}
+
+// filter runs every band over the full input block.
+func (c *Channelizer) filter(emit func(band *Band, samples []float32)) {
+	if len(c.bands) == 0 {
+		return
+	}
+
+	copy(c.re, c.input)
+	for i := range c.im {
+		c.im[i] = 0
+	}
+	fft(c.re, c.im)
+
+	scale := 1 / float64(channelizerSize)
+	for _, band := range c.bands {
+		// Inverse transform as the conjugate of the forward transform of
+		// the conjugate product
+		for k := range c.re {
+			hre, him := band.re[k], band.im[k]
+			if band.Isolated {
+				// A delay minus every other band
+				hre = c.dre[k] - c.sre[k] + hre
+				him = c.dim[k] - c.sim[k] + him
+			}
+			c.bre[k] = c.re[k]*hre - c.im[k]*him
+			c.bim[k] = -(c.re[k]*him + c.im[k]*hre)
+		}
+		fft(c.bre, c.bim)
+
+		// The first taps-1 outputs are wrapped around and discarded
+		for i := range c.out {
+			c.out[i] = float32(c.bre[c.taps-1+i] * scale)
+		}
+		emit(band, c.out)
+	}
+}
+
+// Reset discards buffered input.
+func (c *Channelizer) Reset() {
+	for i := range c.input {
+		c.input[i] = 0
+	}
+	c.fill = c.taps - 1
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
\ No newline at end of file
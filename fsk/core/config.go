@@ -10,6 +10,32 @@ type Config struct {
 	Order       int     // FSK order (2^n symbols)
 	BaudRate    float64 // Symbol rate (symbols per second)
 	SampleRate  int     // Audio sample rate
+
+	// Tones lists the frequency of every symbol explicitly, overriding
+	// BaseFreq, FreqSpacing and Order. Any number of tones from 2 up is
+	// allowed; PackSymbols handles alphabets that are not a power of two.
+	Tones []float64
+
+	// Detector selects the tone detection algorithm used by Decode.
+	// The zero value is the reference correlator.
+	Detector DetectorType
+
+	// TimingRecovery makes Decode locate symbol boundaries itself instead of
+	// assuming the signal starts exactly on the first symbol. It skips
+	// leading and trailing silence and tracks clock drift between sender
+	// and receiver. It is off by default.
+	TimingRecovery bool
+
+	// Modulation selects how Encode generates symbols. The zero value keeps
+	// a separate phase per tone; ModulationCPFSK keeps the phase continuous.
+	// ModulationGFSK and ModulationMSK are always demodulated with the
+	// frequency discriminator, regardless of Detector.
+	Modulation Modulation
+
+	// BT is the Gaussian filter bandwidth-time product for ModulationGFSK.
+	// Lower values give a narrower spectrum and more intersymbol
+	// interference. Zero selects DefaultBT.
+	BT float64
 }
 
 // DefaultConfig returns a default FSK configuration.
@@ -23,6 +49,27 @@ func DefaultConfig() Config {
 	}
 }
 
+// KCSConfig returns the Kansas City Standard tone pair at 300 baud:
+// 1200 Hz for a zero bit and 2400 Hz for a one bit.
+func KCSConfig() Config {
+	config := DefaultConfig()
+	config.Tones = []float64{1200, 2400}
+	config.BaudRate = 300
+	return config
+}
+
+// Bell103Config returns the Bell 103 originate tone pair at 300 baud:
+// 1070 Hz (space) for a zero bit and 1270 Hz (mark) for a one bit. The
+// tones are closer than the baud rate, so it uses the frequency
+// discriminator.
+func Bell103Config() Config {
+	config := DefaultConfig()
+	config.Tones = []float64{1070, 1270}
+	config.BaudRate = 300
+	config.Detector = DetectorDiscriminator
+	return config
+}
+
 // UltrasonicConfig returns a configuration optimized for ultrasonic communication.
 func UltrasonicConfig() Config {
 	return Config{
@@ -32,4 +79,23 @@ func UltrasonicConfig() Config {
 		BaudRate:    100,
 		SampleRate:  48000,
 	}
+}
+
+// tones returns the frequency of every symbol.
+func (c Config) tones() []float64 {
+	if len(c.Tones) > 0 {
+		return append([]float64(nil), c.Tones...)
+	}
+
+	// MSK fixes the modulation index at 0.5
+	spacing := c.FreqSpacing
+	if c.Modulation == ModulationMSK {
+		spacing = c.BaudRate / 2
+	}
+
+	tones := make([]float64, 1<<c.Order) // 2^order frequencies
+	for i := range tones {
+		tones[i] = c.BaseFreq + float64(i)*spacing
+	}
+	return tones
 }
\ No newline at end of file
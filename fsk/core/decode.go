@@ -1,56 +1,83 @@
 package core
 
+// Symbol is a demodulated symbol together with its position in the signal.
+type Symbol struct {
+	Value  int // Index of the detected tone
+	Offset int // Sample offset of the symbol start
+}
+
 // Decode converts FSK-modulated audio signal back to binary data.
 func (m *Modem) Decode(signal []float32) []byte {
-	symbolCount := len(signal) / m.symbolPeriod
-	if symbolCount == 0 {
+	symbols := m.DecodeSymbols(signal)
+	if len(symbols) == 0 {
 		return nil
 	}
 
-	symbols := make([]int, symbolCount)
+	values := make([]int, len(symbols))
+	for i, symbol := range symbols {
+		values[i] = symbol.Value
+	}
 
-	// For each symbol period, determine which frequency has the highest correlation
-	for symbolIdx := 0; symbolIdx < symbolCount; symbolIdx++ {
-		start := symbolIdx * m.symbolPeriod
-		end := start + m.symbolPeriod
-		if end > len(signal) {
-			end = len(signal)
-		}
+	return m.UnpackSymbols(values)
+}
 
-		maxCorrelation := -1.0
-		detectedSymbol := 0
+// DecodeSymbols detects the symbols in signal without unpacking them into bytes.
+func (m *Modem) DecodeSymbols(signal []float32) []Symbol {
+	if m.config.TimingRecovery {
+		return m.demodulateSynchronized(signal)
+	}
+	return m.demodulate(signal)
+}
 
-		// Test correlation with each possible frequency
-		for freqIdx, freq := range m.frequencies {
-			correlation := m.correlateWithFrequency(signal[start:end], freq)
-			if correlation > maxCorrelation {
-				maxCorrelation = correlation
-				detectedSymbol = freqIdx
-			}
-		}
+// demodulate detects symbols assuming that sample 0 is the start of symbol 0.
+func (m *Modem) demodulate(signal []float32) []Symbol {
+	var symbols []Symbol
+	meter := m.newToneMeter()
 
-		symbols[symbolIdx] = detectedSymbol
+	// For each symbol period, determine which frequency has the highest magnitude
+	for symbolIdx := 0; m.SymbolStart(symbolIdx+1) <= len(signal); symbolIdx++ {
+		start := m.SymbolStart(symbolIdx)
+		end := m.SymbolStart(symbolIdx + 1)
+		symbols = append(symbols, Symbol{Value: meter.detectSymbol(signal[start:end]), Offset: start})
 	}
 
-	// Convert symbols back to bytes
-	bitsPerSymbol := m.config.Order
-	totalBits := symbolCount * bitsPerSymbol
-	byteCount := (totalBits + 7) / 8 // Ceiling division
+	return symbols
+}
+
+// toneMeter measures the modem's tones in one signal. Detectors keep state
+// between blocks, so each decode or stream gets its own and the Modem can be
+// shared.
+type toneMeter struct {
+	detector   ToneDetector
+	magnitudes []float64 // Output of the last measure
+}
 
-	output := make([]byte, byteCount)
+// newToneMeter returns a tone meter using the modem's detector, with any
+// sliding window one symbol long.
+func (m *Modem) newToneMeter() *toneMeter {
+	return &toneMeter{
+		detector:   newToneDetector(m.detectorType, m.frequencies, m.config.SampleRate, m.symbolPeriod),
+		magnitudes: make([]float64, len(m.frequencies)),
+	}
+}
 
-	bitIndex := 0
-	for _, symbol := range symbols {
-		for bit := 0; bit < bitsPerSymbol && bitIndex < totalBits; bit++ {
-			byteIdx := bitIndex / 8
-			bitInByte := 7 - (bitIndex % 8) // MSB first
+// measure returns the magnitude of every tone in block. The slice is
+// reused by the next call.
+func (t *toneMeter) measure(block []float32) []float64 {
+	t.detector.Magnitudes(block, t.magnitudes)
+	return t.magnitudes
+}
 
-			if symbol&(1<<(bitsPerSymbol-1-bit)) != 0 {
-				output[byteIdx] |= 1 << bitInByte
-			}
-			bitIndex++
+// detectSymbol returns the index of the strongest tone in block.
+func (t *toneMeter) detectSymbol(block []float32) int {
+	maxMagnitude := -1.0
+	detectedSymbol := 0
+	for freqIdx, magnitude := range t.measure(block) {
+		if magnitude > maxMagnitude {
+			maxMagnitude = magnitude
+			detectedSymbol = freqIdx
 		}
 	}
 
-	return output
+	return detectedSymbol
 }
\ No newline at end of file
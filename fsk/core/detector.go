@@ -0,0 +1,487 @@
+package core
+
+import "math"
+
+// DetectorType selects the tone detection algorithm used by Decode.
+type DetectorType int
+
+const (
+	// DetectorCorrelation correlates every sample against I/Q references
+	// generated with math.Sin/math.Cos. It is the slowest detector and is
+	// kept as the reference implementation.
+	DetectorCorrelation DetectorType = iota
+
+	// DetectorGoertzel runs one Goertzel filter per tone with precomputed
+	// coefficients. It is the recommended detector for block decoding.
+	DetectorGoertzel
+
+	// DetectorSlidingDFT tracks one DFT bin per tone and updates it with
+	// every new sample, which suits sample-by-sample streaming.
+	DetectorSlidingDFT
+
+	// DetectorFFT computes a radix-2 FFT of the block and reads the bin
+	// closest to each tone. It pays off with large alphabets.
+	DetectorFFT
+
+	// DetectorDiscriminator estimates the instantaneous frequency with a
+	// quadrature discriminator and scores each tone by its distance from the
+	// estimate. It is the matched demodulator for GFSK and MSK, whose tones
+	// are too close or too smeared for energy detection.
+	DetectorDiscriminator
+)
+
+// String returns the detector name.
+func (d DetectorType) String() string {
+	switch d {
+	case DetectorCorrelation:
+		return "correlation"
+	case DetectorGoertzel:
+		return "goertzel"
+	case DetectorSlidingDFT:
+		return "sliding-dft"
+	case DetectorFFT:
+		return "fft"
+	case DetectorDiscriminator:
+		return "discriminator"
+	}
+	return "unknown"
+}
+
+// ToneDetector measures the magnitude of a fixed set of tones in a block of samples.
+// Implementations keep scratch state and are not safe for concurrent use.
+type ToneDetector interface {
+	// Magnitudes writes the magnitude of each tone in block to out, which must
+	// hold one entry per tone. Results are normalized by the block length so
+	// that all detectors report comparable values.
+	Magnitudes(block []float32, out []float64)
+}
+
+// NewToneDetector creates a detector of the given type for frequencies at sampleRate.
+// A DetectorSlidingDFT window is sized by the first block.
+func NewToneDetector(kind DetectorType, frequencies []float64, sampleRate int) ToneDetector {
+	return newToneDetector(kind, frequencies, sampleRate, 0)
+}
+
+// newToneDetector is NewToneDetector with the sliding DFT window fixed at
+// windowSize samples, or sized by the first block when it is zero.
+func newToneDetector(kind DetectorType, frequencies []float64, sampleRate int, windowSize int) ToneDetector {
+	frequencies = append([]float64(nil), frequencies...)
+
+	switch kind {
+	case DetectorGoertzel:
+		return newGoertzelDetector(frequencies, sampleRate)
+	case DetectorSlidingDFT:
+		d := &slidingDFTDetector{frequencies: frequencies, sampleRate: sampleRate}
+		if windowSize > 0 {
+			d.sdft = NewSlidingDFT(frequencies, sampleRate, windowSize)
+		}
+		return d
+	case DetectorFFT:
+		return &fftDetector{frequencies: frequencies, sampleRate: sampleRate}
+	case DetectorDiscriminator:
+		return newDiscriminatorDetector(frequencies, sampleRate)
+	default:
+		return &correlationDetector{frequencies: frequencies, sampleRate: sampleRate}
+	}
+}
+
+// correlationDetector correlates the block with I/Q references for every tone.
+type correlationDetector struct {
+	frequencies []float64
+	sampleRate  int
+}
+
+func (d *correlationDetector) Magnitudes(block []float32, out []float64) {
+	for i, freq := range d.frequencies {
+		out[i] = correlate(block, freq, d.sampleRate)
+	}
+}
+
+// correlate measures the energy of a reference frequency in signal.
+// The signal is correlated against in-phase (cosine) and quadrature (sine)
+// references and the magnitude of the pair is returned, so the result does
+// not depend on the phase of the received tone.
+func correlate(signal []float32, freq float64, sampleRate int) float64 {
+	if len(signal) == 0 {
+		return 0
+	}
+
+	phaseIncrement := 2 * math.Pi * freq / float64(sampleRate)
+
+	var inPhase, quadrature float64
+	phase := 0.0
+
+	for _, sample := range signal {
+		inPhase += float64(sample) * math.Cos(phase)
+		quadrature += float64(sample) * math.Sin(phase)
+		phase += phaseIncrement
+
+		if phase >= 2*math.Pi {
+			phase -= 2 * math.Pi
+		}
+	}
+
+	return math.Hypot(inPhase, quadrature) / float64(len(signal))
+}
+
+// goertzelDetector evaluates a single DFT term per tone with the Goertzel recurrence.
+type goertzelDetector struct {
+	coeffs []float64 // 2*cos(w) for each tone
+}
+
+func newGoertzelDetector(frequencies []float64, sampleRate int) *goertzelDetector {
+	d := &goertzelDetector{coeffs: make([]float64, len(frequencies))}
+	for i, freq := range frequencies {
+		d.coeffs[i] = 2 * math.Cos(2*math.Pi*freq/float64(sampleRate))
+	}
+	return d
+}
+
+func (d *goertzelDetector) Magnitudes(block []float32, out []float64) {
+	if len(block) == 0 {
+		for i := range out[:len(d.coeffs)] {
+			out[i] = 0
+		}
+		return
+	}
+
+	for i, coeff := range d.coeffs {
+		var s1, s2 float64
+		for _, sample := range block {
+			s0 := float64(sample) + coeff*s1 - s2
+			s2 = s1
+			s1 = s0
+		}
+
+		power := s1*s1 + s2*s2 - coeff*s1*s2
+		if power < 0 {
+			power = 0 // rounding noise
+		}
+		out[i] = math.Sqrt(power) / float64(len(block))
+	}
+}
+
+// SlidingDFT tracks the DFT of the most recent samples at a set of frequencies.
+// Each Push updates every bin in constant time, so the magnitudes are
+// available at every sample offset without recomputing the whole window.
+type SlidingDFT struct {
+	window   []float64    // circular history of the last len(window) samples
+	pos      int          // next write position in window
+	filled   int          // number of valid samples in window
+	rotators []complex128 // e^(-jw) per tone
+	phasors  []complex128 // e^(-jwn) per tone for the current sample n
+	wraps    []complex128 // e^(jwN) per tone, rotates the phasor back N samples
+	bins     []complex128 // running sums per tone
+	pushes   int          // samples since the last phasor renormalization
+}
+
+// NewSlidingDFT creates a sliding DFT over windowSize samples for the given frequencies.
+func NewSlidingDFT(frequencies []float64, sampleRate int, windowSize int) *SlidingDFT {
+	if windowSize < 1 {
+		windowSize = 1
+	}
+
+	s := &SlidingDFT{
+		rotators: make([]complex128, len(frequencies)),
+		phasors:  make([]complex128, len(frequencies)),
+		wraps:    make([]complex128, len(frequencies)),
+		bins:     make([]complex128, len(frequencies)),
+	}
+	for i, freq := range frequencies {
+		omega := 2 * math.Pi * freq / float64(sampleRate)
+		s.rotators[i] = complex(math.Cos(omega), -math.Sin(omega))
+	}
+	s.Resize(windowSize)
+
+	return s
+}
+
+// Resize changes the window length and clears all state.
+func (s *SlidingDFT) Resize(windowSize int) {
+	if windowSize < 1 {
+		windowSize = 1
+	}
+	if cap(s.window) >= windowSize {
+		s.window = s.window[:windowSize]
+	} else {
+		s.window = make([]float64, windowSize)
+	}
+
+	for i, rotator := range s.rotators {
+		omega := -math.Atan2(imag(rotator), real(rotator))
+		angle := omega * float64(windowSize)
+		s.wraps[i] = complex(math.Cos(angle), math.Sin(angle))
+	}
+	s.Reset()
+}
+
+// Reset clears the window and all bins.
+func (s *SlidingDFT) Reset() {
+	for i := range s.window {
+		s.window[i] = 0
+	}
+	for i := range s.bins {
+		s.bins[i] = 0
+		s.phasors[i] = 1
+	}
+	s.pos = 0
+	s.filled = 0
+	s.pushes = 0
+}
+
+// Push adds a sample to the window, dropping the oldest one once the window is full.
+func (s *SlidingDFT) Push(sample float32) {
+	x := float64(sample)
+	old := s.window[s.pos]
+	s.window[s.pos] = x
+	s.pos++
+	if s.pos == len(s.window) {
+		s.pos = 0
+	}
+	if s.filled < len(s.window) {
+		s.filled++
+	}
+
+	for i, phasor := range s.phasors {
+		s.bins[i] += phasor * complex(x, 0)
+		if old != 0 {
+			s.bins[i] -= phasor * s.wraps[i] * complex(old, 0)
+		}
+		s.phasors[i] = phasor * s.rotators[i]
+	}
+
+	// Keep the rotating phasors on the unit circle
+	s.pushes++
+	if s.pushes >= 1024 {
+		s.pushes = 0
+		for i, phasor := range s.phasors {
+			mag := math.Hypot(real(phasor), imag(phasor))
+			s.phasors[i] = phasor / complex(mag, 0)
+		}
+	}
+}
+
+// Magnitudes writes the magnitude of each tone over the current window to out,
+// normalized by the number of samples in the window.
+func (s *SlidingDFT) Magnitudes(out []float64) {
+	n := s.filled
+	if n == 0 {
+		n = 1
+	}
+	for i, bin := range s.bins {
+		out[i] = math.Hypot(real(bin), imag(bin)) / float64(n)
+	}
+}
+
+// slidingDFTDetector adapts SlidingDFT to block detection by pushing every
+// sample of the block through a cleared window. The window keeps its size:
+// a shorter block fills part of it and a longer one is measured over its
+// last window, so symbols of alternating length never recompute it.
+type slidingDFTDetector struct {
+	frequencies []float64
+	sampleRate  int
+	sdft        *SlidingDFT // Created by the first block without a window size
+}
+
+func (d *slidingDFTDetector) Magnitudes(block []float32, out []float64) {
+	if d.sdft == nil {
+		d.sdft = NewSlidingDFT(d.frequencies, d.sampleRate, len(block))
+	} else {
+		d.sdft.Reset()
+	}
+
+	for _, sample := range block {
+		d.sdft.Push(sample)
+	}
+	d.sdft.Magnitudes(out)
+}
+
+// fftDetector reads tone magnitudes from the FFT bin nearest to each tone.
+type fftDetector struct {
+	frequencies []float64
+	sampleRate  int
+	re, im      []float64
+}
+
+func (d *fftDetector) Magnitudes(block []float32, out []float64) {
+	if len(block) == 0 {
+		for i := range d.frequencies {
+			out[i] = 0
+		}
+		return
+	}
+
+	size := 1
+	for size < len(block) {
+		size <<= 1
+	}
+	if cap(d.re) < size {
+		d.re = make([]float64, size)
+		d.im = make([]float64, size)
+	}
+	d.re = d.re[:size]
+	d.im = d.im[:size]
+
+	for i := range d.re {
+		d.re[i] = 0
+		d.im[i] = 0
+	}
+	for i, sample := range block {
+		d.re[i] = float64(sample)
+	}
+
+	fft(d.re, d.im)
+
+	for i, freq := range d.frequencies {
+		bin := int(math.Round(freq * float64(size) / float64(d.sampleRate)))
+		if bin < 0 {
+			bin = 0
+		}
+		if bin > size/2 {
+			bin = size / 2
+		}
+		out[i] = math.Hypot(d.re[bin], d.im[bin]) / float64(len(block))
+	}
+}
+
+// discriminatorDetector mixes the block down around the centre of the tone
+// set, low-pass filters it and measures the average phase advance per
+// sample, which gives the dominant frequency. Each tone then scores the
+// block amplitude weighted by how close the estimate lies to it, falling
+// linearly to zero across the span of the tone set.
+type discriminatorDetector struct {
+	frequencies []float64
+	sampleRate  int
+	center      float64 // Centre of the tone set in Hz
+	span        float64 // Distance between the lowest and highest tone in Hz
+	halfWidth   int     // Half width of the moving average low-pass filter
+	re, im      []float64
+	tmpRe       []float64
+	tmpIm       []float64
+}
+
+func newDiscriminatorDetector(frequencies []float64, sampleRate int) *discriminatorDetector {
+	d := &discriminatorDetector{frequencies: frequencies, sampleRate: sampleRate}
+	if len(frequencies) == 0 {
+		return d
+	}
+
+	low, high := frequencies[0], frequencies[0]
+	for _, freq := range frequencies {
+		low = math.Min(low, freq)
+		high = math.Max(high, freq)
+	}
+	d.center = (low + high) / 2
+	d.span = high - low
+
+	// Mixing leaves an image at twice the centre frequency (folded around
+	// the sample rate); size the filter so its first null lands on it.
+	fs := float64(sampleRate)
+	image := math.Abs(2*d.center - math.Round(2*d.center/fs)*fs)
+	if image > 0 {
+		d.halfWidth = int(math.Round(fs / image / 2))
+	}
+
+	return d
+}
+
+func (d *discriminatorDetector) Magnitudes(block []float32, out []float64) {
+	for i := range d.frequencies {
+		out[i] = 0
+	}
+	if len(block) < 2 {
+		return
+	}
+
+	n := len(block)
+	if cap(d.re) < n {
+		d.re = make([]float64, n)
+		d.im = make([]float64, n)
+		d.tmpRe = make([]float64, n)
+		d.tmpIm = make([]float64, n)
+	}
+	re, im := d.re[:n], d.im[:n]
+
+	// Mix to baseband
+	var energy float64
+	omega := 2 * math.Pi * d.center / float64(d.sampleRate)
+	for i, sample := range block {
+		x := float64(sample)
+		sin, cos := math.Sincos(omega * float64(i))
+		re[i] = x * cos
+		im[i] = -x * sin
+		energy += x * x
+	}
+
+	// Two passes of a centred moving average, short enough to follow
+	// symbol transitions but long enough to reject the mixing image
+	halfWidth := d.halfWidth
+	if halfWidth > n/8 {
+		halfWidth = n / 8
+	}
+	for pass := 0; pass < 2; pass++ {
+		movingAverage(re, d.tmpRe[:n], halfWidth)
+		movingAverage(im, d.tmpIm[:n], halfWidth)
+	}
+
+	// Average phase advance, weighted by a Hann window so the centre of the
+	// block, which is least affected by neighbouring symbols, dominates
+	var sumRe, sumIm float64
+	for i := 1; i < n; i++ {
+		weight := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		sumRe += weight * (re[i]*re[i-1] + im[i]*im[i-1])
+		sumIm += weight * (im[i]*re[i-1] - re[i]*im[i-1])
+	}
+	if sumRe == 0 && sumIm == 0 {
+		return
+	}
+	estimate := d.center + math.Atan2(sumIm, sumRe)*float64(d.sampleRate)/(2*math.Pi)
+
+	// Scale like the other detectors: a tone of amplitude A scores A/2
+	amplitude := math.Sqrt(energy/float64(n)) / math.Sqrt2
+	for i, freq := range d.frequencies {
+		closeness := 1.0
+		if d.span > 0 {
+			closeness = 1 - math.Abs(estimate-freq)/d.span
+		}
+		if closeness > 0 {
+			out[i] = amplitude * closeness
+		}
+	}
+}
+
+// movingAverage replaces values with their centred moving average over
+// 2*halfWidth+1 samples, shortened at the edges. scratch must be as long as
+// values.
+func movingAverage(values, scratch []float64, halfWidth int) {
+	if halfWidth < 1 {
+		return
+	}
+
+	// scratch holds prefix sums shifted by one: scratch[i] = sum(values[:i])
+	var sum float64
+	for i, value := range values {
+		scratch[i] = sum
+		sum += value
+	}
+
+	n := len(values)
+	prefix := func(i int) float64 {
+		if i >= n {
+			return sum
+		}
+		return scratch[i]
+	}
+
+	for i := range values {
+		start := i - halfWidth
+		if start < 0 {
+			start = 0
+		}
+		end := i + halfWidth + 1
+		if end > n {
+			end = n
+		}
+		values[i] = (prefix(end) - prefix(start)) / float64(end-start)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// tone returns n samples of a unit sine at freq.
+func tone(freq float64, n, sampleRate int) []float32 {
+	samples := make([]float32, n)
+	for i := range samples {
+		samples[i] = float32(math.Sin(2 * math.Pi * freq * float64(i) / float64(sampleRate)))
+	}
+	return samples
+}
+
+func TestToneDetectors(t *testing.T) {
+	const sampleRate = 48000
+	frequencies := []float64{1000, 1200, 1400, 1600}
+
+	for detector := DetectorCorrelation; detector <= DetectorDiscriminator; detector++ {
+		for _, size := range []int{480, 512, 1000} {
+			d := NewToneDetector(detector, frequencies, sampleRate)
+			magnitudes := make([]float64, len(frequencies))
+
+			for want, freq := range frequencies {
+				d.Magnitudes(tone(freq, size, sampleRate), magnitudes)
+				got := 0
+				for i, magnitude := range magnitudes {
+					if magnitude > magnitudes[got] {
+						got = i
+					}
+				}
+				if got != want {
+					t.Errorf("%v, %d samples of %g Hz: strongest tone %g Hz (%v)", detector, size, freq, frequencies[got], magnitudes)
+				}
+			}
+		}
+	}
+}
+
+func TestSlidingDFTMatchesGoertzel(t *testing.T) {
+	const sampleRate, size = 48000, 480
+	frequencies := []float64{1000, 1300}
+	signal := append(tone(1000, size, sampleRate), tone(1300, size, sampleRate)...)
+
+	sliding := NewSlidingDFT(frequencies, sampleRate, size)
+	goertzel := NewToneDetector(DetectorGoertzel, frequencies, sampleRate)
+	got := make([]float64, len(frequencies))
+	want := make([]float64, len(frequencies))
+
+	// Every full window must match a block transform of the same samples
+	for i, sample := range signal {
+		sliding.Push(sample)
+		if i+1 < size || (i+1)%60 != 0 {
+			continue
+		}
+		sliding.Magnitudes(got)
+		goertzel.Magnitudes(signal[i+1-size:i+1], want)
+		for k := range got {
+			if math.Abs(got[k]-want[k]) > 1e-3*math.Max(1, want[k]) {
+				t.Errorf("window ending at %d, %g Hz: sliding DFT %g, Goertzel %g", i, frequencies[k], got[k], want[k])
+			}
+		}
+	}
+}
+
+func TestSlidingDFTDetectorFixedWindow(t *testing.T) {
+	const sampleRate, size = 48000, 480
+	frequencies := []float64{1000, 1300}
+	signal := append(tone(1000, size, sampleRate), tone(1300, size+1, sampleRate)...)
+
+	detector := newToneDetector(DetectorSlidingDFT, frequencies, sampleRate, size)
+	goertzel := NewToneDetector(DetectorGoertzel, frequencies, sampleRate)
+	got := make([]float64, len(frequencies))
+	want := make([]float64, len(frequencies))
+
+	// A longer block is measured over its last window, a shorter one whole
+	tests := []struct {
+		name     string
+		block    []float32
+		measured []float32
+	}{
+		{"whole window", signal[:size], signal[:size]},
+		{"longer block", signal[size-1 : 2*size], signal[size : 2*size]},
+		{"shorter block", signal[size : size+size*3/4], signal[size : size+size*3/4]},
+		{"boundary window", signal[size/2 : size+size/2], signal[size/2 : size+size/2]},
+	}
+	for _, tt := range tests {
+		detector.Magnitudes(tt.block, got)
+		goertzel.Magnitudes(tt.measured, want)
+		for k := range got {
+			if math.Abs(got[k]-want[k]) > 1e-3*math.Max(1, want[k]) {
+				t.Errorf("%s, %g Hz: sliding DFT %g, Goertzel %g", tt.name, frequencies[k], got[k], want[k])
+			}
+		}
+		if window := len(detector.(*slidingDFTDetector).sdft.window); window != size {
+			t.Errorf("%s: window resized to %d samples, want %d", tt.name, window, size)
+		}
+	}
+}
\ No newline at end of file
@@ -4,43 +4,125 @@ import "math"
 
 // Encode converts binary data to FSK-modulated audio signal.
 func (m *Modem) Encode(data []byte) []float32 {
-	bitsPerSymbol := m.config.Order
-	totalBits := len(data) * 8
-	symbolCount := (totalBits + bitsPerSymbol - 1) / bitsPerSymbol // Ceiling division
-
-	output := make([]float32, symbolCount*m.symbolPeriod)
-
-	bitIndex := 0
-	for symbolIdx := 0; symbolIdx < symbolCount; symbolIdx++ {
-		// Extract bits for this symbol
-		symbol := 0
-		for bit := 0; bit < bitsPerSymbol && bitIndex < totalBits; bit++ {
-			byteIdx := bitIndex / 8
-			bitInByte := 7 - (bitIndex % 8) // MSB first
-
-			if data[byteIdx]&(1<<bitInByte) != 0 {
-				symbol |= 1 << (bitsPerSymbol - 1 - bit)
-			}
-			bitIndex++
-		}
+	return m.EncodeSymbols(m.PackSymbols(data))
+}
+
+// EncodeSymbols generates the waveform for a sequence of symbol indexes.
+// Each symbol must be in the range [0, len(Frequencies())).
+func (m *Modem) EncodeSymbols(symbols []int) []float32 {
+	return m.synthesize(m.newOscillator(), nil, symbols, 0, len(symbols), 0)
+}
+
+// oscillator holds the phase of one signal being generated. Each Encode and
+// each StreamEncoder gets its own, so the Modem can be shared.
+type oscillator struct {
+	phase []float64 // Phase accumulators for each frequency
+	nco   float64   // Shared oscillator phase for continuous-phase modulation
+}
+
+// newOscillator returns an oscillator with every phase at zero.
+func (m *Modem) newOscillator() *oscillator {
+	return &oscillator{phase: make([]float64, len(m.frequencies))}
+}
+
+// synthesize appends the waveform of symbols[from:to] to output, advancing
+// osc. index is the absolute symbol index of symbols[0], which places every
+// symbol on the sample clock so that consecutive calls join without drift.
+// GFSK looks at up to pulseReach symbols on either side, clamped to the
+// slice.
+func (m *Modem) synthesize(osc *oscillator, output []float32, symbols []int, from, to, index int) []float32 {
+	if from >= to {
+		return output
+	}
 
+	offset := m.SymbolStart(index + from)
+	length := m.SymbolStart(index+to) - offset
+	start := len(output)
+	if cap(output)-start < length {
+		grown := make([]float32, start, start+length)
+		copy(grown, output)
+		output = grown
+	}
+	output = output[:start+length]
+	block := output[start:]
+
+	if m.config.Modulation == ModulationGFSK {
+		m.encodeGaussian(osc, symbols, from, to, index, block)
+		return output
+	}
+
+	for symbolIdx := from; symbolIdx < to; symbolIdx++ {
 		// Generate waveform for this symbol
+		symbol := symbols[symbolIdx]
 		freq := m.frequencies[symbol]
 		phaseIncrement := 2 * math.Pi * freq / float64(m.config.SampleRate)
+		symbolBlock := block[m.SymbolStart(index+symbolIdx)-offset : m.SymbolStart(index+symbolIdx+1)-offset]
 
-		for sampleIdx := 0; sampleIdx < m.symbolPeriod; sampleIdx++ {
-			outputIdx := symbolIdx*m.symbolPeriod + sampleIdx
-			if outputIdx < len(output) {
-				output[outputIdx] = float32(0.5 * math.Sin(m.phase[symbol]))
-				m.phase[symbol] += phaseIncrement
+		switch m.config.Modulation {
+		case ModulationCPFSK, ModulationMSK:
+			osc.nco = oscillate(symbolBlock, osc.nco, phaseIncrement)
+		default:
+			osc.phase[symbol] = oscillate(symbolBlock, osc.phase[symbol], phaseIncrement)
+		}
+	}
 
-				// Keep phase in range [0, 2π]
-				if m.phase[symbol] >= 2*math.Pi {
-					m.phase[symbol] -= 2 * math.Pi
-				}
+	return output
+}
+
+// encodeGaussian generates GFSK for symbols[from:to] into output: each
+// sample's frequency is the Gaussian-filtered symbol frequency trajectory at
+// that instant, and a single oscillator integrates it. The filtered
+// trajectory is the sum of the Gaussian step responses to every frequency
+// change within reach. Symbols beyond either end of the slice are taken to
+// repeat the first and last symbol.
+func (m *Modem) encodeGaussian(osc *oscillator, symbols []int, from, to, index int, output []float32) {
+	scale := 2 * math.Pi / float64(m.config.SampleRate)
+	tone := func(k int) float64 {
+		return m.frequencies[symbols[clampIndex(k, len(symbols))]]
+	}
+
+	offset := m.SymbolStart(index + from)
+	for symbolIdx := from; symbolIdx < to; symbolIdx++ {
+		for n := m.SymbolStart(index + symbolIdx); n < m.SymbolStart(index+symbolIdx+1); n++ {
+			t := float64(n) + 0.5 // Sample centre
+			freq := tone(symbolIdx - m.pulseReach)
+			for k := symbolIdx - m.pulseReach + 1; k <= symbolIdx+m.pulseReach; k++ {
+				step := 0.5 * (1 + math.Erf((t-float64(index+k)*m.samplesPerSymbol)*m.pulseScale))
+				freq += (tone(k) - tone(k-1)) * step
+			}
+
+			output[n-offset] = float32(0.5 * math.Sin(osc.nco))
+			osc.nco += scale * freq
+
+			// Keep phase in range [0, 2π]
+			if osc.nco >= 2*math.Pi {
+				osc.nco -= 2 * math.Pi
 			}
 		}
 	}
+}
 
-	return output
+func clampIndex(index, length int) int {
+	if index < 0 {
+		return 0
+	}
+	if index >= length {
+		return length - 1
+	}
+	return index
+}
+
+// oscillate fills block with a sine wave starting at phase and returns the
+// phase following the last sample.
+func oscillate(block []float32, phase, phaseIncrement float64) float64 {
+	for i := range block {
+		block[i] = float32(0.5 * math.Sin(phase))
+		phase += phaseIncrement
+
+		// Keep phase in range [0, 2π]
+		if phase >= 2*math.Pi {
+			phase -= 2 * math.Pi
+		}
+	}
+	return phase
 }
\ No newline at end of file
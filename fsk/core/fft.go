@@ -0,0 +1,51 @@
+package core
+
+import "math"
+
+// fft computes an in-place radix-2 decimation-in-time FFT.
+// len(re) must equal len(im) and be a power of two.
+func fft(re, im []float64) {
+	n := len(re)
+	if n < 2 {
+		return
+	}
+
+	// Bit-reversal permutation
+	j := 0
+	for i := 1; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+
+	// Butterflies
+	for size := 2; size <= n; size <<= 1 {
+		half := size >> 1
+		angle := -2 * math.Pi / float64(size)
+		wRe, wIm := math.Cos(angle), math.Sin(angle)
+
+		for start := 0; start < n; start += size {
+			tRe, tIm := 1.0, 0.0
+			for k := 0; k < half; k++ {
+				a := start + k
+				b := a + half
+
+				xRe := re[b]*tRe - im[b]*tIm
+				xIm := re[b]*tIm + im[b]*tRe
+
+				re[b] = re[a] - xRe
+				im[b] = im[a] - xIm
+				re[a] += xRe
+				im[a] += xIm
+
+				tRe, tIm = tRe*wRe-tIm*wIm, tRe*wIm+tIm*wRe
+			}
+		}
+	}
+}
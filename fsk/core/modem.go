@@ -3,25 +3,38 @@ package core
 import "math"
 
 // Modem represents an FSK modem with encoding/decoding capabilities.
+// Encode and Decode keep their state per call, so a Modem can be shared by
+// several goroutines.
 type Modem struct {
-	config       Config
-	symbolPeriod int // Samples per symbol
-	frequencies  []float64
-	phase        []float64 // Phase accumulators for each frequency
+	config           Config
+	symbolPeriod     int     // Whole samples per symbol, used for analysis windows
+	samplesPerSymbol float64 // Exact samples per symbol, may be fractional
+	frequencies      []float64
+	pulseScale       float64      // Gaussian filter scale for GFSK, in 1/samples
+	pulseReach       int          // Symbols on each side covered by the Gaussian filter
+	detectorType     DetectorType // Detector used by Decode
+	blockBits        int          // Data bits carried by each block of symbols
+	blockSymbols     int          // Symbols per block
 }
 
 // New creates a new FSK modem with the given configuration.
 func New(config Config) *Modem {
+	samplesPerSymbol := float64(config.SampleRate) / config.BaudRate
 	modem := &Modem{
-		config:       config,
-		symbolPeriod: int(float64(config.SampleRate) / config.BaudRate),
-		frequencies:  make([]float64, 1<<config.Order), // 2^order frequencies
-		phase:        make([]float64, 1<<config.Order),
+		config:           config,
+		symbolPeriod:     int(samplesPerSymbol),
+		samplesPerSymbol: samplesPerSymbol,
+		frequencies:      config.tones(),
 	}
+	modem.blockBits, modem.blockSymbols = packing(len(modem.frequencies))
 
-	// Calculate frequencies for each symbol
-	for i := 0; i < len(modem.frequencies); i++ {
-		modem.frequencies[i] = config.BaseFreq + float64(i)*config.FreqSpacing
+	if config.Modulation == ModulationGFSK {
+		modem.pulseScale, modem.pulseReach = gaussianFilter(samplesPerSymbol, config.BT)
+	}
+
+	modem.detectorType = config.Detector
+	if config.Modulation.usesDiscriminator() {
+		modem.detectorType = DetectorDiscriminator
 	}
 
 	return modem
@@ -37,27 +50,20 @@ func (m *Modem) Frequencies() []float64 {
 	return append([]float64(nil), m.frequencies...) // Return copy
 }
 
-// SymbolPeriod returns the number of samples per symbol.
+// SymbolPeriod returns the number of whole samples per symbol.
 func (m *Modem) SymbolPeriod() int {
 	return m.symbolPeriod
 }
 
-// correlateWithFrequency calculates correlation between signal and reference frequency.
-func (m *Modem) correlateWithFrequency(signal []float32, freq float64) float64 {
-	phaseIncrement := 2 * math.Pi * freq / float64(m.config.SampleRate)
-
-	var correlation float64
-	phase := 0.0
-
-	for _, sample := range signal {
-		reference := math.Sin(phase)
-		correlation += float64(sample) * reference
-		phase += phaseIncrement
-
-		if phase >= 2*math.Pi {
-			phase -= 2 * math.Pi
-		}
-	}
+// SamplesPerSymbol returns the exact, possibly fractional, number of
+// samples per symbol.
+func (m *Modem) SamplesPerSymbol() float64 {
+	return m.samplesPerSymbol
+}
 
-	return math.Abs(correlation) / float64(len(signal))
+// SymbolStart returns the sample offset at which symbol index starts.
+// Boundaries are rounded from an exact sample clock, so symbols alternate
+// between whole sample lengths without drifting from the nominal baud rate.
+func (m *Modem) SymbolStart(index int) int {
+	return int(math.Floor(float64(index)*m.samplesPerSymbol + 0.5))
 }
\ No newline at end of file
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDecodeTonePhase(t *testing.T) {
+	tests := []struct {
+		name  string
+		start float64 // Phase of the first symbol's tone
+		step  float64 // Phase added at every following symbol
+	}{
+		{"sine", 0, 0},
+		{"cosine", math.Pi / 2, 0},
+		{"inverted", math.Pi, 0},
+		{"arbitrary", 1.234, 0},
+		{"changing every symbol", 0.3, 2.1},
+	}
+
+	message := []byte("Phase")
+	config := DefaultConfig()
+	modem := New(config)
+	period := modem.SymbolPeriod()
+	frequencies := modem.Frequencies()
+
+	for _, tt := range tests {
+		// Build the signal by hand so every symbol starts at the chosen phase
+		var signal []float32
+		for i := 0; i < len(message)*8/config.Order; i++ {
+			symbol := 0
+			for bit := 0; bit < config.Order; bit++ {
+				index := i*config.Order + bit
+				symbol = symbol<<1 | int(message[index/8]>>(7-index%8)&1)
+			}
+			phase := tt.start + float64(i)*tt.step
+			for n := 0; n < period; n++ {
+				seconds := float64(n) / float64(config.SampleRate)
+				signal = append(signal, float32(0.5*math.Sin(2*math.Pi*frequencies[symbol]*seconds+phase)))
+			}
+		}
+
+		if got := modem.Decode(signal); string(got) != string(message) {
+			t.Errorf("%s: Decode = %q, want %q", tt.name, got, message)
+		}
+	}
+}
+
+func TestRoundTripDetectors(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	for detector := DetectorCorrelation; detector <= DetectorDiscriminator; detector++ {
+		config := DefaultConfig()
+		config.Detector = detector
+
+		if got := New(config).Decode(New(config).Encode(data)); !bytes.Equal(got, data) {
+			t.Errorf("%v: Decode returned %d bytes that differ from the %d encoded", detector, len(got), len(data))
+		}
+	}
+}
+
+func TestRoundTripModulations(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	tests := []struct {
+		modulation Modulation
+		order      int
+		bt         float64
+	}{
+		{ModulationFSK, 2, 0},
+		{ModulationCPFSK, 2, 0},
+		{ModulationGFSK, 2, DefaultBT},
+		{ModulationGFSK, 1, 0.3},
+		{ModulationMSK, 1, 0},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.Modulation = tt.modulation
+		config.Order = tt.order
+		config.BT = tt.bt
+
+		if got := New(config).Decode(New(config).Encode(data)); !bytes.Equal(got, data) {
+			t.Errorf("%v, order %d, BT %g: Decode returned %d bytes that differ from the %d encoded", tt.modulation, tt.order, tt.bt, len(got), len(data))
+		}
+	}
+
+	// MSK with the Bell 202 tones, a full baud rate above zero
+	config := DefaultConfig()
+	config.Modulation = ModulationMSK
+	config.Order = 1
+	config.BaseFreq = 1200
+	config.BaudRate = 1200
+	if got := New(config).Decode(New(config).Encode(data)); !bytes.Equal(got, data) {
+		t.Errorf("MSK at 1200 baud: Decode returned %d bytes that differ from the %d encoded", len(got), len(data))
+	}
+}
+
+func TestRoundTripOrders(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	for _, order := range []int{1, 2, 3, 4} {
+		config := DefaultConfig()
+		config.Order = order
+		modem := New(config)
+		if n := len(modem.Frequencies()); n != 1<<order {
+			t.Errorf("order %d: %d tones, want %d", order, n, 1<<order)
+		}
+
+		// Bits that do not fill a byte come back as a zero padding byte
+		want := append([]byte(nil), data...)
+		if len(data)*8%order != 0 {
+			want = append(want, 0)
+		}
+		if got := New(config).Decode(modem.Encode(data)); !bytes.Equal(got, want) {
+			t.Errorf("order %d: Decode returned %d bytes that differ from the %d expected", order, len(got), len(want))
+		}
+	}
+}
+
+func TestRoundTripFractionalSymbolPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate int
+		baudRate   float64
+		baseFreq   float64
+		spacing    float64
+	}{
+		{"1200 baud at 44.1 kHz", 44100, 1200, 1200, 1200},
+		{"45.45 baud RTTY", 48000, 45.45, 2125, 170},
+		{"70 baud", 48000, 70, 1000, 200},
+	}
+
+	data := make([]byte, 1024)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.SampleRate = tt.sampleRate
+		config.BaudRate = tt.baudRate
+		config.BaseFreq = tt.baseFreq
+		config.FreqSpacing = tt.spacing
+		config.Order = 1
+		modem := New(config)
+
+		// The last boundary must land where the exact clock puts it, without
+		// the drift of a truncated symbol period
+		symbols := len(data) * 8
+		want := int(math.Round(float64(symbols) * float64(tt.sampleRate) / tt.baudRate))
+		if got := modem.SymbolStart(symbols); got != want {
+			t.Errorf("%s: SymbolStart(%d) = %d, want %d", tt.name, symbols, got, want)
+		}
+
+		signal := modem.Encode(data)
+		if len(signal) != want {
+			t.Errorf("%s: Encode returned %d samples, want %d", tt.name, len(signal), want)
+		}
+		if got := New(config).Decode(signal); !bytes.Equal(got, data) {
+			t.Errorf("%s: Decode returned %d bytes that differ from the %d encoded", tt.name, len(got), len(data))
+		}
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	config := DefaultConfig()
+	config.Detector = DetectorSlidingDFT
+	config.TimingRecovery = true
+	config.Modulation = ModulationCPFSK
+	modem := New(config)
+	message := []byte("shared modem")
+	want := modem.Encode(message)
+
+	var wg sync.WaitGroup
+	signals := make([][]float32, 8)
+	results := make([][]byte, len(signals))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			signals[i] = modem.Encode(message)
+			results[i] = modem.Decode(append(make([]float32, 123), signals[i]...))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, got := range results {
+		if !reflect.DeepEqual(signals[i], want) {
+			t.Errorf("goroutine %d: Encode differs from a lone call", i)
+		}
+		if !bytes.Equal(got, message) {
+			t.Errorf("goroutine %d: Decode = %q, want %q", i, got, message)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,66 @@
+package core
+
+import "math"
+
+// DefaultBT is the Gaussian filter bandwidth-time product used by
+// ModulationGFSK when Config.BT is not set.
+const DefaultBT = 0.5
+
+// Modulation selects how Encode generates the waveform of each symbol.
+type Modulation int
+
+const (
+	// ModulationFSK keeps an independent phase accumulator per tone, so the
+	// phase jumps at every symbol change. This is the original behaviour.
+	ModulationFSK Modulation = iota
+
+	// ModulationCPFSK drives every tone from a single oscillator whose
+	// frequency changes at symbol boundaries, keeping the phase continuous.
+	// This avoids the clicks and spectral splatter of ModulationFSK.
+	ModulationCPFSK
+
+	// ModulationGFSK is continuous-phase FSK whose frequency steps are
+	// smoothed by a Gaussian filter with bandwidth-time product Config.BT,
+	// which narrows the spectrum at the cost of some intersymbol interference.
+	ModulationGFSK
+
+	// ModulationMSK is continuous-phase FSK with modulation index 0.5: the
+	// tones are spaced by half the baud rate and Config.FreqSpacing is ignored.
+	ModulationMSK
+)
+
+// String returns the modulation name.
+func (mod Modulation) String() string {
+	switch mod {
+	case ModulationFSK:
+		return "fsk"
+	case ModulationCPFSK:
+		return "cpfsk"
+	case ModulationGFSK:
+		return "gfsk"
+	case ModulationMSK:
+		return "msk"
+	}
+	return "unknown"
+}
+
+// usesDiscriminator reports whether the modulation is demodulated with the
+// frequency discriminator rather than the configured tone detector.
+func (mod Modulation) usesDiscriminator() bool {
+	return mod == ModulationGFSK || mod == ModulationMSK
+}
+
+// gaussianFilter returns the parameters of the GFSK frequency filter for
+// bandwidth-time product bt: the scale that turns a time offset in samples
+// into the argument of math.Erf, and how many symbols on either side of a
+// sample still move its frequency.
+func gaussianFilter(samplesPerSymbol, bt float64) (float64, int) {
+	if bt <= 0 {
+		bt = DefaultBT
+	}
+
+	sigma := math.Sqrt(math.Ln2) / (2 * math.Pi * bt) * samplesPerSymbol
+	reach := int(math.Ceil(4*sigma/samplesPerSymbol)) + 1
+
+	return 1 / (sigma * math.Sqrt2), reach
+}
@@ -0,0 +1,99 @@
+package core
+
+import "math"
+
+// Spectrum computes Hann-windowed power spectra of fixed-size blocks.
+//
+// It is not safe for concurrent use.
+type Spectrum struct {
+	size       int
+	sampleRate int
+	window     []float64
+	scale      float64 // Turns |X|² into the power of a sine on the bin
+	enbw       float64 // Equivalent noise bandwidth of the window, in bins
+	re, im     []float64
+}
+
+// NewSpectrum creates a spectrum of size samples, rounded up to a power of
+// two, for audio at sampleRate.
+func NewSpectrum(size, sampleRate int) *Spectrum {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+
+	window := make([]float64, n)
+	var sum, squares float64
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		sum += window[i]
+		squares += window[i] * window[i]
+	}
+
+	return &Spectrum{
+		size:       n,
+		sampleRate: sampleRate,
+		window:     window,
+		scale:      2 / (sum * sum),
+		enbw:       float64(n) * squares / (sum * sum),
+		re:         make([]float64, n),
+		im:         make([]float64, n),
+	}
+}
+
+// Size returns the number of samples per block.
+func (s *Spectrum) Size() int {
+	return s.size
+}
+
+// Bins returns the number of bins, from DC to the Nyquist frequency.
+func (s *Spectrum) Bins() int {
+	return s.size/2 + 1
+}
+
+// BinWidth returns the spacing of the bins in Hz.
+func (s *Spectrum) BinWidth() float64 {
+	return float64(s.sampleRate) / float64(s.size)
+}
+
+// Frequency returns the centre frequency of bin.
+func (s *Spectrum) Frequency(bin int) float64 {
+	return float64(bin) * s.BinWidth()
+}
+
+// Bin returns the bin nearest to freq, clamped to the valid range.
+func (s *Spectrum) Bin(freq float64) int {
+	bin := int(math.Round(freq / s.BinWidth()))
+	if bin < 0 {
+		return 0
+	}
+	if bin >= s.Bins() {
+		return s.Bins() - 1
+	}
+	return bin
+}
+
+// NoiseBandwidth returns the equivalent noise bandwidth of the window in
+// bins. Summing the bins covered by a tone gives its power times this.
+func (s *Spectrum) NoiseBandwidth() float64 {
+	return s.enbw
+}
+
+// Power writes the power of each bin of block into out. A sine of
+// amplitude A centred on a bin reads A²/2 there. block is zero padded or
+// truncated to Size samples and out must hold Bins values.
+func (s *Spectrum) Power(block []float32, out []float64) {
+	for i := range s.re {
+		s.re[i] = 0
+		s.im[i] = 0
+		if i < len(block) {
+			s.re[i] = float64(block[i]) * s.window[i]
+		}
+	}
+
+	fft(s.re, s.im)
+
+	for k := 0; k < s.Bins(); k++ {
+		out[k] = (s.re[k]*s.re[k] + s.im[k]*s.im[k]) * s.scale
+	}
+}
\ No newline at end of file
@@ -0,0 +1,419 @@
+package core
+
+import (
+	"io"
+	"math"
+)
+
+// StreamEncoder modulates bytes written to it into audio samples that are
+// read back in blocks of any size. Oscillator phase, the fractional sample
+// clock and bits that do not yet fill a symbol carry over between writes, so
+// writing a message in pieces and calling Flush produces the same signal as
+// Encode. It is not safe for concurrent use.
+type StreamEncoder struct {
+	modem    *Modem
+	osc      *oscillator
+	bits     uint      // Data bits not yet packed, right aligned
+	bitCount int       // Number of valid bits in bits
+	symbols  []int     // Packed symbols, starting with GFSK history
+	next     int       // First entry of symbols not yet modulated
+	index    int       // Absolute symbol index of symbols[0]
+	samples  []float32 // Modulated samples not yet read
+}
+
+// NewStreamEncoder creates a stream encoder with the configuration of modem.
+// The encoder keeps its own oscillator state, so modem stays free for other use.
+func NewStreamEncoder(modem *Modem) *StreamEncoder {
+	return &StreamEncoder{modem: modem, osc: modem.newOscillator()}
+}
+
+// Write packs p into symbols and modulates every symbol it completes.
+// It always consumes all of p and never fails.
+func (e *StreamEncoder) Write(p []byte) (int, error) {
+	m := e.modem
+	for _, b := range p {
+		e.bits = e.bits<<8 | uint(b)
+		e.bitCount += 8
+
+		for e.bitCount >= m.blockBits {
+			e.bitCount -= m.blockBits
+			e.appendBlock(int(e.bits >> uint(e.bitCount)))
+			e.bits &= 1<<uint(e.bitCount) - 1
+		}
+	}
+
+	// GFSK needs the symbols that follow before it can shape the last ones
+	e.modulate(len(e.symbols) - m.pulseReach)
+	return len(p), nil
+}
+
+// Flush pads pending bits with zeros to a whole symbol block, as Encode does
+// at the end of its data, and modulates every remaining symbol.
+func (e *StreamEncoder) Flush() {
+	if e.bitCount > 0 {
+		e.appendBlock(int(e.bits << uint(e.modem.blockBits-e.bitCount)))
+		e.bits, e.bitCount = 0, 0
+	}
+	e.modulate(len(e.symbols))
+}
+
+// ReadSamples copies up to len(samples) modulated samples into samples and
+// returns how many it copied.
+func (e *StreamEncoder) ReadSamples(samples []float32) int {
+	n := copy(samples, e.samples)
+	e.samples = append(e.samples[:0], e.samples[n:]...)
+	return n
+}
+
+// Buffered returns the number of modulated samples waiting to be read.
+func (e *StreamEncoder) Buffered() int {
+	return len(e.samples)
+}
+
+// Reset discards pending bits, symbols and samples and restarts the sample
+// clock and oscillator.
+func (e *StreamEncoder) Reset() {
+	*e = StreamEncoder{modem: e.modem, osc: e.modem.newOscillator()}
+}
+
+func (e *StreamEncoder) appendBlock(value int) {
+	start := len(e.symbols)
+	for i := 0; i < e.modem.blockSymbols; i++ {
+		e.symbols = append(e.symbols, 0)
+	}
+	e.modem.writeBlock(e.symbols[start:], value)
+}
+
+// modulate generates the samples of symbols up to end and drops symbols
+// that GFSK no longer needs as history.
+func (e *StreamEncoder) modulate(end int) {
+	if end <= e.next {
+		return
+	}
+
+	e.samples = e.modem.synthesize(e.osc, e.samples, e.symbols, e.next, end, e.index)
+	e.next = end
+
+	if drop := e.next - e.modem.pulseReach; drop > 0 {
+		e.symbols = append(e.symbols[:0], e.symbols[drop:]...)
+		e.index += drop
+		e.next -= drop
+	}
+}
+
+// StreamDecoder demodulates audio samples pushed to it in blocks of any size
+// and returns the decoded bytes through Read. Symbol timing, symbols that do
+// not yet fill a byte and samples of symbols still arriving carry over
+// between writes.
+//
+// Without timing recovery the stream is read on a fixed symbol grid from its
+// first sample, like Decode. With timing recovery the decoder waits for a
+// transmission to start, locks onto its symbol timing and tracks it until the
+// carrier disappears, then drops any incomplete byte and waits for the next
+// one. Carrier is judged per symbol with the thresholds of a CarrierConfig,
+// DefaultCarrierConfig unless SetCarrierConfig changes them: a transmission
+// is acquired when its first symbols are loud and pure enough, so noise
+// alone never produces bytes, and it ends after more than Hang weak symbols,
+// which are never decoded.
+//
+// It is not safe for concurrent use.
+type StreamDecoder struct {
+	modem    *Modem
+	meter    *toneMeter   // Detector state for symbol decisions
+	carrier  ToneDetector // Goertzel detector used to measure tone purity
+	levels   []float64    // Scratch buffer for carrier
+	squelch  CarrierConfig
+	floor    float64   // Level below which a locked symbol counts as weak
+	buffer   []float32 // Samples not yet consumed
+	consumed int       // Samples dropped from the front of the stream
+
+	locked   bool
+	clock    *symbolClock
+	pos      float64 // Start of the next symbol within buffer
+	previous int     // Previous symbol value, -1 at the start of a transmission
+	weak     []int   // Weak symbols held until the carrier recovers or falls
+	index    int     // Next symbol index on the fixed grid without timing recovery
+
+	symbols  []int  // Symbols that do not yet fill a block
+	bits     uint   // Decoded bits that do not yet fill a byte, right aligned
+	bitCount int    // Number of valid bits in bits
+	output   []byte // Decoded bytes not yet read
+	flushed  bool   // Flush was called and no samples arrived since
+}
+
+// NewStreamDecoder creates a stream decoder with the configuration of modem.
+// The decoder keeps its own detector state, so modem stays free for other use.
+func NewStreamDecoder(modem *Modem) *StreamDecoder {
+	config := DefaultCarrierConfig()
+	return &StreamDecoder{
+		modem:    modem,
+		meter:    modem.newToneMeter(),
+		carrier:  NewToneDetector(DetectorGoertzel, modem.frequencies, modem.config.SampleRate),
+		levels:   make([]float64, len(modem.frequencies)),
+		squelch:  config,
+		floor:    config.floor(),
+		previous: -1,
+	}
+}
+
+// SetCarrierConfig replaces the thresholds with which the decoder, with
+// timing recovery, acquires a transmission and notices its end. The zero
+// CarrierConfig locks onto any activity and holds the lock until Flush.
+func (d *StreamDecoder) SetCarrierConfig(config CarrierConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	d.squelch = config
+	d.floor = config.floor()
+	return nil
+}
+
+// WriteSamples appends samples to the stream and decodes every symbol they
+// complete.
+func (d *StreamDecoder) WriteSamples(samples []float32) {
+	d.buffer = append(d.buffer, samples...)
+	d.flushed = false
+	d.process(false)
+}
+
+// Flush decodes the symbols left at the end of the stream, ending any
+// transmission in progress. Call it once no more samples will arrive.
+func (d *StreamDecoder) Flush() {
+	d.process(true)
+	d.unlock()
+	d.consume(len(d.buffer))
+	d.flushed = true
+}
+
+// Read copies decoded bytes into p. While the stream is open it returns
+// 0, nil when no decoded bytes are pending, since more may follow further
+// calls to WriteSamples. Once Flush has ended the stream and every byte has
+// been read, it returns io.EOF.
+func (d *StreamDecoder) Read(p []byte) (int, error) {
+	if len(d.output) == 0 {
+		if d.flushed && len(p) > 0 {
+			return 0, io.EOF
+		}
+		return 0, nil
+	}
+	n := copy(p, d.output)
+	d.output = append(d.output[:0], d.output[n:]...)
+	return n, nil
+}
+
+// Buffered returns the number of decoded bytes waiting to be read.
+func (d *StreamDecoder) Buffered() int {
+	return len(d.output)
+}
+
+// Locked reports whether the decoder is locked onto a transmission. It is
+// always true without timing recovery.
+func (d *StreamDecoder) Locked() bool {
+	return d.locked || !d.modem.config.TimingRecovery
+}
+
+// Reset discards all buffered samples, partial symbols and unread bytes.
+func (d *StreamDecoder) Reset() {
+	d.buffer = d.buffer[:0]
+	d.consumed = 0
+	d.index = 0
+	d.output = d.output[:0]
+	d.flushed = false
+	d.unlock()
+}
+
+func (d *StreamDecoder) process(final bool) {
+	if !d.modem.config.TimingRecovery {
+		d.processGrid()
+		return
+	}
+
+	for {
+		if !d.locked && !d.acquire(final) {
+			return
+		}
+		if !d.track(final) {
+			return
+		}
+	}
+}
+
+// processGrid decodes every whole symbol on the fixed grid.
+func (d *StreamDecoder) processGrid() {
+	m := d.modem
+	for {
+		start := m.SymbolStart(d.index) - d.consumed
+		end := m.SymbolStart(d.index+1) - d.consumed
+		if end > len(d.buffer) {
+			d.consume(start)
+			return
+		}
+		d.emit(d.meter.detectSymbol(d.buffer[start:end]))
+		d.index++
+	}
+}
+
+// acquire looks for the start of a transmission in the buffer and locks
+// onto its symbol timing. It reports whether it locked.
+func (d *StreamDecoder) acquire(final bool) bool {
+	m := d.modem
+	period := m.symbolPeriod
+	window := (acquisitionSymbols + 1) * period
+	if len(d.buffer) < window && !final {
+		return false
+	}
+
+	first, last := m.findActivity(d.buffer)
+	if last-first < period/2 {
+		// Silence: keep only what may hold the start of a quiet onset
+		d.consume(len(d.buffer) - 2*period)
+		return false
+	}
+	if len(d.buffer)-first < window && !final {
+		d.consume(first - period)
+		return false
+	}
+
+	start := m.acquire(d.meter, d.buffer, first, len(d.buffer))
+	var purity, level float64
+	var count int
+	for i := 0; i < acquisitionSymbols; i++ {
+		block := m.symbolWindow(d.buffer, start+m.SymbolStart(i), len(d.buffer))
+		if block == nil {
+			break
+		}
+		p, rms := measurePurity(d.carrier, d.levels, block)
+		purity += p
+		level += rms
+		count++
+	}
+	if count == 0 || purity/float64(count) < d.squelch.Open || level/float64(count) < d.squelch.Squelch {
+		// Noise or an onset too short or too quiet to lock onto
+		d.consume(first + period)
+		return false
+	}
+
+	d.locked = true
+	d.clock = newSymbolClock(m.samplesPerSymbol)
+	d.pos = float64(start)
+	d.previous = -1
+	return true
+}
+
+// track decodes symbols of the locked transmission until it needs more
+// samples, returning false, or loses the carrier, returning true.
+func (d *StreamDecoder) track(final bool) bool {
+	m := d.modem
+	period := m.symbolPeriod
+
+	for {
+		start := int(math.Round(d.pos))
+
+		// Leave room for the boundary window and timing corrections
+		if start+period+period/2 > len(d.buffer) && !final {
+			d.consume(start - period)
+			return false
+		}
+		block := m.symbolWindow(d.buffer, start, len(d.buffer))
+		if block == nil {
+			d.consume(start - period)
+			return false
+		}
+		symbol := d.meter.detectSymbol(block)
+
+		// A tone change marks a symbol boundary we can measure
+		if d.previous >= 0 && symbol != d.previous {
+			d.pos += d.clock.correct(m.boundaryError(d.meter, d.buffer, start, d.previous, symbol))
+
+			start = int(math.Round(d.pos))
+			block = m.symbolWindow(d.buffer, start, len(d.buffer))
+			if block == nil {
+				d.consume(start - period)
+				return false
+			}
+			symbol = d.meter.detectSymbol(block)
+		}
+
+		purity, rms := measurePurity(d.carrier, d.levels, block)
+		if purity < d.squelch.Close || rms < d.floor {
+			if len(d.weak) == d.squelch.Hang {
+				d.unlock()
+				d.consume(start + period)
+				return true
+			}
+			d.weak = append(d.weak, symbol)
+		} else {
+			// The carrier held, so the weak symbols were part of it
+			for _, held := range d.weak {
+				d.emit(held)
+			}
+			d.weak = d.weak[:0]
+			d.emit(symbol)
+		}
+		d.previous = symbol
+		d.pos += d.clock.period
+	}
+}
+
+// measurePurity returns the tone purity of block, measured with detector
+// into levels, and the block's RMS level.
+func measurePurity(detector ToneDetector, levels []float64, block []float32) (purity, rms float64) {
+	var energy float64
+	for _, sample := range block {
+		energy += float64(sample) * float64(sample)
+	}
+	if energy == 0 {
+		return 0, 0
+	}
+	rms = math.Sqrt(energy / float64(len(block)))
+
+	// A tone of amplitude A has an RMS of A/√2 and a magnitude of A/2
+	detector.Magnitudes(block, levels)
+	var tones float64
+	for _, level := range levels {
+		tones += level * level
+	}
+	return math.Sqrt(tones) / (rms / math.Sqrt2), rms
+}
+
+// emit collects a decoded symbol and appends every byte it completes.
+func (d *StreamDecoder) emit(symbol int) {
+	m := d.modem
+	d.symbols = append(d.symbols, symbol)
+	if len(d.symbols) < m.blockSymbols {
+		return
+	}
+
+	d.bits = d.bits<<uint(m.blockBits) | uint(m.blockValue(d.symbols))
+	d.bitCount += m.blockBits
+	d.symbols = d.symbols[:0]
+
+	for d.bitCount >= 8 {
+		d.bitCount -= 8
+		d.output = append(d.output, byte(d.bits>>uint(d.bitCount)))
+		d.bits &= 1<<uint(d.bitCount) - 1
+	}
+}
+
+// unlock ends the current transmission, dropping symbols and bits that do
+// not fill a byte.
+func (d *StreamDecoder) unlock() {
+	d.locked = false
+	d.previous = -1
+	d.weak = d.weak[:0]
+	d.symbols = d.symbols[:0]
+	d.bits, d.bitCount = 0, 0
+}
+
+// consume drops the first n samples from the buffer.
+func (d *StreamDecoder) consume(n int) {
+	if n <= 0 {
+		return
+	}
+	if n > len(d.buffer) {
+		n = len(d.buffer)
+	}
+	d.buffer = append(d.buffer[:0], d.buffer[n:]...)
+	d.consumed += n
+	d.pos -= float64(n)
+}
\ No newline at end of file
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestStreamEncoderMatchesEncode(t *testing.T) {
+	tests := []struct {
+		name       string
+		modulation Modulation
+		order      int
+		tones      []float64
+		baudRate   float64
+	}{
+		{"FSK", ModulationFSK, 2, nil, 100},
+		{"CPFSK", ModulationCPFSK, 2, nil, 100},
+		{"GFSK", ModulationGFSK, 2, nil, 100},
+		{"MSK", ModulationMSK, 1, nil, 100},
+		{"8 tones", ModulationCPFSK, 3, nil, 100},
+		{"3 tones", ModulationCPFSK, 0, []float64{1000, 1200, 1400}, 100},
+		{"fractional symbol period", ModulationCPFSK, 2, nil, 70},
+	}
+
+	data := []byte("The quick brown fox")
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.Modulation = tt.modulation
+		config.Order = tt.order
+		config.Tones = tt.tones
+		config.BaudRate = tt.baudRate
+		want := New(config).Encode(data)
+
+		for _, size := range []int{1, 2, 5, len(data)} {
+			encoder := NewStreamEncoder(New(config))
+			var got []float32
+			block := make([]float32, 1000)
+			for start := 0; start < len(data); start += size {
+				encoder.Write(data[start:min(start+size, len(data))])
+				n := encoder.ReadSamples(block)
+				got = append(got, block[:n]...)
+			}
+			encoder.Flush()
+			for encoder.Buffered() > 0 {
+				n := encoder.ReadSamples(block)
+				got = append(got, block[:n]...)
+			}
+
+			if len(got) != len(want) {
+				t.Errorf("%s in writes of %d: %d samples, Encode gives %d", tt.name, size, len(got), len(want))
+				continue
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-want[i])) > 1e-4 {
+					t.Errorf("%s in writes of %d: sample %d = %v, Encode gives %v", tt.name, size, i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestStreamDecoderMatchesDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		timing  bool
+		padding int // Silence on both sides, in samples
+	}{
+		{"fixed grid", false, 0},
+		{"timing recovery", true, 0},
+		{"timing recovery with silence", true, 4801},
+	}
+
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.TimingRecovery = tt.timing
+		signal := append(make([]float32, tt.padding), New(config).Encode(data)...)
+		signal = append(signal, make([]float32, tt.padding)...)
+
+		for _, size := range []int{1, 100, 480, 4096, len(signal)} {
+			decoder := NewStreamDecoder(New(config))
+			var got []byte
+			for start := 0; start < len(signal); start += size {
+				decoder.WriteSamples(signal[start:min(start+size, len(signal))])
+				p := make([]byte, decoder.Buffered())
+				decoder.Read(p)
+				got = append(got, p...)
+			}
+			decoder.Flush()
+			p := make([]byte, decoder.Buffered())
+			decoder.Read(p)
+			got = append(got, p...)
+
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s in blocks of %d: StreamDecoder returned %d bytes that differ from the %d encoded", tt.name, size, len(got), len(data))
+			}
+		}
+	}
+}
+
+// decodeStream writes signal to a new stream decoder in blocks of size
+// samples and returns everything it decoded.
+func decodeStream(config Config, signal []float32, size int) []byte {
+	decoder := NewStreamDecoder(New(config))
+	var decoded []byte
+	for len(signal) > 0 {
+		n := min(size, len(signal))
+		decoder.WriteSamples(signal[:n])
+		signal = signal[n:]
+
+		p := make([]byte, decoder.Buffered())
+		decoder.Read(p)
+		decoded = append(decoded, p...)
+	}
+	return decoded
+}
+
+func TestStreamDecoderFilteredTail(t *testing.T) {
+	config := DefaultConfig()
+	config.BaseFreq, config.FreqSpacing, config.BaudRate = 18000, 400, 100
+	config.TimingRecovery = true
+
+	// A channelizer leaves rounding residue shaped like the tones after the
+	// transmission, which must not extend it
+	channelizer := NewChannelizer(config.SampleRate)
+	channelizer.AddIsolated(17800, 19400)
+	tests := []string{"a", "abcdefghij", "a longer message through the channelizer"}
+	for _, message := range tests {
+		signal := New(config).Encode([]byte(message))
+		signal = append(signal, make([]float32, config.SampleRate/4)...)
+
+		var filtered []float32
+		channelizer.Reset()
+		channelizer.Process(signal, func(band *Band, samples []float32) {
+			filtered = append(filtered, samples...)
+		})
+
+		for _, size := range []int{256, 6146} {
+			if got := decodeStream(config, filtered, size); string(got) != message {
+				t.Errorf("%q in blocks of %d: decoded %q", message, size, got)
+			}
+		}
+	}
+}
+
+func TestStreamDecoderReadEOF(t *testing.T) {
+	config := DefaultConfig()
+	config.TimingRecovery = false
+	message := []byte("end of stream")
+	signal := New(config).Encode(message)
+
+	decoder := NewStreamDecoder(New(config))
+	p := make([]byte, 64)
+	if n, err := decoder.Read(p); n != 0 || err != nil {
+		t.Errorf("Read before any samples = %d, %v, want 0, nil", n, err)
+	}
+
+	decoder.WriteSamples(signal[:len(signal)/2])
+	got := make([]byte, decoder.Buffered())
+	decoder.Read(got)
+	if n, err := decoder.Read(p); n != 0 || err != nil {
+		t.Errorf("Read with nothing pending = %d, %v, want 0, nil", n, err)
+	}
+
+	decoder.WriteSamples(signal[len(signal)/2:])
+	decoder.Flush()
+	rest, err := io.ReadAll(decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got = append(got, rest...); !bytes.Equal(got, message) {
+		t.Errorf("decoded %q, want %q", got, message)
+	}
+	if n, err := decoder.Read(p); n != 0 || err != io.EOF {
+		t.Errorf("Read after Flush = %d, %v, want 0, io.EOF", n, err)
+	}
+
+	decoder.Reset()
+	if n, err := decoder.Read(p); n != 0 || err != nil {
+		t.Errorf("Read after Reset = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestStreamDecoderCarrierConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.TimingRecovery = true
+	message := []byte("faint")
+
+	// About -43 dBFS, below the default squelch
+	signal := append(make([]float32, 4800), New(config).Encode(message)...)
+	signal = append(signal, make([]float32, 4800)...)
+	for i := range signal {
+		signal[i] *= 0.02
+	}
+
+	lowered := DefaultCarrierConfig()
+	lowered.Squelch = 0.001
+	tests := []struct {
+		name    string
+		carrier CarrierConfig
+		want    []byte
+	}{
+		{"default", DefaultCarrierConfig(), nil},
+		{"lowered squelch", lowered, message},
+	}
+	for _, tt := range tests {
+		decoder := NewStreamDecoder(New(config))
+		if err := decoder.SetCarrierConfig(tt.carrier); err != nil {
+			t.Fatal(err)
+		}
+		decoder.WriteSamples(signal)
+		decoder.Flush()
+		got := make([]byte, decoder.Buffered())
+		decoder.Read(got)
+		if !bytes.Equal(got, tt.want) && len(got)+len(tt.want) > 0 {
+			t.Errorf("%s: decoded %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if err := NewStreamDecoder(New(config)).SetCarrierConfig(CarrierConfig{Open: 0.2, Close: 0.4}); !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("SetCarrierConfig with Open below Close = %v, want ErrInvalidThreshold", err)
+	}
+}
\ No newline at end of file
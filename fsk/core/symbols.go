@@ -0,0 +1,125 @@
+package core
+
+import "math"
+
+// packing chooses how data bits map onto an alphabet of the given size.
+// Bits are taken in blocks of bits and each block is written as symbols
+// base-alphabet digits. Power-of-two alphabets use one symbol per block;
+// other sizes use the block of at most 8 bits that carries the most bits
+// per symbol, e.g. 3 bits in 2 symbols for a 3-tone alphabet.
+func packing(alphabet int) (bits, symbols int) {
+	if alphabet < 2 {
+		return 1, 1
+	}
+
+	bitsPerSymbol := math.Log2(float64(alphabet))
+	if bitsPerSymbol == math.Trunc(bitsPerSymbol) {
+		return int(bitsPerSymbol), 1
+	}
+
+	bits, symbols = 1, 1
+	for n := 1; ; n++ {
+		b := int(math.Floor(float64(n)*bitsPerSymbol + 1e-9))
+		if b > 8 {
+			break
+		}
+		if b*symbols > bits*n {
+			bits, symbols = b, n
+		}
+	}
+	return bits, symbols
+}
+
+// SymbolCount returns the number of symbols needed to carry byteCount bytes.
+func (m *Modem) SymbolCount(byteCount int) int {
+	totalBits := byteCount * 8
+	blocks := (totalBits + m.blockBits - 1) / m.blockBits // Ceiling division
+	return blocks * m.blockSymbols
+}
+
+// PackSymbols splits data into symbols, MSB first. The last block is
+// padded with zero bits when the data does not fill it.
+func (m *Modem) PackSymbols(data []byte) []int {
+	totalBits := len(data) * 8
+	symbols := make([]int, m.SymbolCount(len(data)))
+
+	bitIndex := 0
+	for blockStart := 0; blockStart < len(symbols); blockStart += m.blockSymbols {
+		// Extract bits for this block
+		value := 0
+		for bit := 0; bit < m.blockBits; bit++ {
+			value <<= 1
+			if bitIndex < totalBits {
+				byteIdx := bitIndex / 8
+				bitInByte := 7 - (bitIndex % 8) // MSB first
+
+				if data[byteIdx]&(1<<bitInByte) != 0 {
+					value |= 1
+				}
+			}
+			bitIndex++
+		}
+
+		m.writeBlock(symbols[blockStart:blockStart+m.blockSymbols], value)
+	}
+
+	return symbols
+}
+
+// UnpackSymbols joins symbols back into bytes, MSB first. With power-of-two
+// alphabets a trailing partial byte is zero padded; otherwise the padding
+// bits of the last block are dropped, along with any incomplete block.
+func (m *Modem) UnpackSymbols(symbols []int) []byte {
+	alphabet := len(m.frequencies)
+	blocks := len(symbols) / m.blockSymbols
+	totalBits := blocks * m.blockBits
+
+	byteCount := totalBits / 8
+	if 1<<m.blockBits == alphabet {
+		byteCount = (totalBits + 7) / 8 // Ceiling division
+	}
+
+	output := make([]byte, byteCount)
+
+	bitIndex := 0
+	for block := 0; block < blocks; block++ {
+		value := m.blockValue(symbols[block*m.blockSymbols : (block+1)*m.blockSymbols])
+
+		for bit := m.blockBits - 1; bit >= 0 && bitIndex < byteCount*8; bit-- {
+			byteIdx := bitIndex / 8
+			bitInByte := 7 - (bitIndex % 8) // MSB first
+
+			if value&(1<<bit) != 0 {
+				output[byteIdx] |= 1 << bitInByte
+			}
+			bitIndex++
+		}
+	}
+
+	return output
+}
+
+// writeBlock writes value as base-alphabet digits into block, most
+// significant first. block must hold blockSymbols entries.
+func (m *Modem) writeBlock(block []int, value int) {
+	alphabet := len(m.frequencies)
+	for i := len(block) - 1; i >= 0; i-- {
+		block[i] = value % alphabet
+		value /= alphabet
+	}
+}
+
+// blockValue returns the data bits carried by a block of blockSymbols
+// symbols. Digit combinations that no bit pattern produces, which only
+// corrupted blocks contain, saturate to the largest value.
+func (m *Modem) blockValue(block []int) int {
+	alphabet := len(m.frequencies)
+	value := 0
+	for _, symbol := range block {
+		value = value*alphabet + symbol
+	}
+	if maxValue := 1<<m.blockBits - 1; value > maxValue {
+		value = maxValue
+	}
+	return value
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPacking(t *testing.T) {
+	tests := []struct {
+		alphabet      int
+		bits, symbols int
+	}{
+		{2, 1, 1},
+		{3, 3, 2},
+		{4, 2, 1},
+		{5, 2, 1},
+		{6, 5, 2},
+		{7, 8, 3},
+		{8, 3, 1},
+		{10, 3, 1},
+		{16, 4, 1},
+	}
+
+	for _, tt := range tests {
+		if bits, symbols := packing(tt.alphabet); bits != tt.bits || symbols != tt.symbols {
+			t.Errorf("packing(%d) = %d bits in %d symbols, want %d in %d", tt.alphabet, bits, symbols, tt.bits, tt.symbols)
+		}
+	}
+}
+
+func TestPackSymbols(t *testing.T) {
+	data := []byte{0x00, 0xFF, 0xA5, 0x3C, 0x81}
+	for alphabet := 2; alphabet <= 16; alphabet++ {
+		config := DefaultConfig()
+		config.Tones = make([]float64, alphabet)
+		for i := range config.Tones {
+			config.Tones[i] = 1000 + 200*float64(i)
+		}
+		modem := New(config)
+
+		for n := 0; n <= len(data); n++ {
+			symbols := modem.PackSymbols(data[:n])
+			if len(symbols) != modem.SymbolCount(n) {
+				t.Errorf("%d tones, %d bytes: %d symbols, SymbolCount says %d", alphabet, n, len(symbols), modem.SymbolCount(n))
+			}
+			for _, symbol := range symbols {
+				if symbol < 0 || symbol >= alphabet {
+					t.Fatalf("%d tones: symbol %d out of range", alphabet, symbol)
+				}
+			}
+			// Power-of-two alphabets return the padding bits as a partial byte
+			want := data[:n]
+			if bits, _ := packing(alphabet); 1<<bits == alphabet && n*8%bits != 0 {
+				want = append(append([]byte(nil), want...), 0)
+			}
+			if got := modem.UnpackSymbols(symbols); !bytes.Equal(got, want) {
+				t.Errorf("%d tones: UnpackSymbols(PackSymbols(%x)) = %x", alphabet, data[:n], got)
+			}
+		}
+	}
+}
+
+func TestRoundTripNonPowerOfTwo(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	for _, tones := range [][]float64{
+		{1000, 1200, 1400},
+		{1000, 1200, 1400, 1600, 1800},
+		{1800, 1000, 1400, 1200, 1600, 2000}, // Unsorted
+		{1200, 2400},                         // KCS
+	} {
+		config := DefaultConfig()
+		config.Tones = tones
+
+		if got := New(config).Decode(New(config).Encode(data)); !bytes.Equal(got, data) {
+			t.Errorf("tones %v: Decode returned %d bytes that differ from the %d encoded", tones, len(got), len(data))
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,227 @@
+package core
+
+import "math"
+
+// Timing recovery parameters
+const (
+	timingLoopGain     = 0.5  // Fraction of a measured boundary error applied to the current symbol
+	timingDriftGain    = 0.05 // Fraction of a measured boundary error applied to the period estimate
+	timingMaxDrift     = 0.02 // Largest tracked clock difference between sender and receiver
+	activityContrast   = 1.4  // Signal to noise floor envelope ratio needed to detect silence
+	activityFloor      = 1e-6 // Envelope below which a capture is silent, well under 16-bit quantization
+	acquisitionSymbols = 8    // Symbols examined when searching the initial offset
+	acquisitionSteps   = 32   // Offsets tried per symbol period during acquisition
+)
+
+// symbolClock tracks symbol boundaries with a second-order loop: every
+// measured boundary error nudges the current position and, more slowly,
+// the period estimate, which absorbs sample clock drift between sound cards.
+type symbolClock struct {
+	nominal float64 // Nominal samples per symbol
+	period  float64 // Current samples per symbol estimate
+}
+
+func newSymbolClock(period float64) *symbolClock {
+	return &symbolClock{nominal: period, period: period}
+}
+
+// correct feeds a boundary error in samples (positive when the boundary
+// arrived late) and returns the adjustment to apply to the current position.
+func (c *symbolClock) correct(boundaryError float64) float64 {
+	c.period += timingDriftGain * boundaryError
+
+	maxDrift := c.nominal * timingMaxDrift
+	if c.period > c.nominal+maxDrift {
+		c.period = c.nominal + maxDrift
+	}
+	if c.period < c.nominal-maxDrift {
+		c.period = c.nominal - maxDrift
+	}
+
+	return timingLoopGain * boundaryError
+}
+
+// demodulateSynchronized detects symbols in signal without assuming that it
+// starts on a symbol boundary. Leading and trailing silence are skipped, the
+// first boundary is found by searching for the offset with the strongest
+// tones, and every following boundary is tracked from tone transitions.
+func (m *Modem) demodulateSynchronized(signal []float32) []Symbol {
+	first, last := m.findActivity(signal)
+	if last-first < m.symbolPeriod/2 {
+		return nil
+	}
+
+	meter := m.newToneMeter()
+	clock := newSymbolClock(m.samplesPerSymbol)
+	pos := float64(m.acquire(meter, signal, first, last))
+
+	var symbols []Symbol
+	previous := -1
+	for {
+		start := int(math.Round(pos))
+		block := m.symbolWindow(signal, start, last)
+		if block == nil {
+			break
+		}
+		symbol := meter.detectSymbol(block)
+
+		// A tone change marks a symbol boundary we can measure
+		if previous >= 0 && symbol != previous {
+			pos += clock.correct(m.boundaryError(meter, signal, start, previous, symbol))
+
+			start = int(math.Round(pos))
+			block = m.symbolWindow(signal, start, last)
+			if block == nil {
+				break
+			}
+			symbol = meter.detectSymbol(block)
+		}
+
+		symbols = append(symbols, Symbol{Value: symbol, Offset: start})
+		previous = symbol
+		pos += clock.period
+	}
+
+	return symbols
+}
+
+// symbolWindow returns the symbol starting at start, or nil once fewer than
+// three quarters of a symbol remain before the end of the active signal.
+func (m *Modem) symbolWindow(signal []float32, start, last int) []float32 {
+	if start < 0 {
+		start = 0
+	}
+	end := start + m.symbolPeriod
+	if end > len(signal) {
+		end = len(signal)
+	}
+	limit := last
+	if limit > end {
+		limit = end
+	}
+	if limit-start < m.symbolPeriod*3/4 {
+		return nil
+	}
+	return signal[start:end]
+}
+
+// boundaryError measures how far, in samples, the real boundary between a
+// symbol carrying tone from and one carrying tone to lies after boundary.
+// It compares both tones over a one-symbol window centred on the expected
+// boundary, with meter: each tone's magnitude is proportional to the share
+// of the window it occupies, so their normalized difference gives the offset.
+func (m *Modem) boundaryError(meter *toneMeter, signal []float32, boundary, from, to int) float64 {
+	half := m.symbolPeriod / 2
+	start := boundary - half
+	end := boundary + half
+	if start < 0 || end > len(signal) {
+		return 0
+	}
+
+	magnitudes := meter.measure(signal[start:end])
+	before := magnitudes[from]
+	after := magnitudes[to]
+	if before+after == 0 {
+		return 0
+	}
+
+	return (before - after) / (before + after) * float64(half)
+}
+
+// findActivity returns the sample range that holds the signal, at a
+// resolution of a quarter symbol. The envelope is compared against the
+// noise floor (its quietest window) and the signal level (its loudest
+// window), so a burst is found however small a share of the capture it
+// fills; when they are too close to tell apart the whole input is treated
+// as signal. An envelope that never rises above activityFloor, such as the
+// rounding residue a filter leaves in silence, is silence.
+func (m *Modem) findActivity(signal []float32) (int, int) {
+	window := m.symbolPeriod / 4
+	if window < 1 {
+		window = 1
+	}
+
+	count := len(signal) / window
+	if count == 0 {
+		return 0, len(signal)
+	}
+
+	levels := make([]float64, count)
+	floor, peak := math.Inf(1), 0.0
+	for i := range levels {
+		var energy float64
+		for _, sample := range signal[i*window : (i+1)*window] {
+			energy += float64(sample) * float64(sample)
+		}
+		levels[i] = math.Sqrt(energy / float64(window))
+		floor = math.Min(floor, levels[i])
+		peak = math.Max(peak, levels[i])
+	}
+	if peak < activityFloor {
+		return 0, 0
+	}
+	if peak < floor*activityContrast {
+		return 0, len(signal)
+	}
+
+	threshold := floor + (peak-floor)/2
+	first, last := -1, -1
+	for i, level := range levels {
+		if level >= threshold {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	end := (last + 1) * window
+	if last == count-1 {
+		end = len(signal)
+	}
+	return first * window, end
+}
+
+// acquire searches around the start of activity for the offset at which the
+// first few symbols contain the strongest tones, as measured by meter.
+// Windows that straddle a boundary or leading silence spread their energy
+// and score lower.
+func (m *Modem) acquire(meter *toneMeter, signal []float32, first, last int) int {
+	step := m.symbolPeriod / acquisitionSteps
+	if step < 1 {
+		step = 1
+	}
+	spread := m.symbolPeriod / 4
+
+	best, bestScore := first, -1.0
+	for offset := first - spread; offset <= first+spread; offset += step {
+		if offset < 0 {
+			continue
+		}
+
+		score := 0.0
+		for i := 0; i < acquisitionSymbols; i++ {
+			block := m.symbolWindow(signal, offset+m.SymbolStart(i), last)
+			if block == nil {
+				break
+			}
+			score += maxOf(meter.measure(block)) * float64(len(block))
+		}
+
+		if score > bestScore {
+			best, bestScore = offset, score
+		}
+	}
+
+	return best
+}
+
+func maxOf(values []float64) float64 {
+	max := 0.0
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+	return max
+}
\ No newline at end of file
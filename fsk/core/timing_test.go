@@ -0,0 +1,71 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// surround places signal between silences of padding times its length,
+// with Gaussian noise of the given deviation over the whole capture.
+func surround(signal []float32, padding int, noise float64, rng *rand.Rand) []float32 {
+	silence := len(signal) * padding
+	capture := make([]float32, silence, 2*silence+len(signal))
+	capture = append(capture, signal...)
+	capture = append(capture, make([]float32, silence)...)
+	for i := range capture {
+		capture[i] += float32(rng.NormFloat64() * noise)
+	}
+	return capture
+}
+
+// timingConfig returns the default configuration with timing recovery.
+func timingConfig() Config {
+	config := DefaultConfig()
+	config.TimingRecovery = true
+	return config
+}
+
+func TestTimingRecoveryShortBurst(t *testing.T) {
+	tests := []struct {
+		name    string
+		padding int
+		noise   float64
+	}{
+		{"no padding", 0, 0},
+		{"digital silence", 10, 0},
+		{"noise 0.01", 10, 0.01},
+		{"noise 0.05", 10, 0.05},
+		{"long noise", 50, 0.01},
+	}
+
+	message := []byte("Hi")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := New(timingConfig())
+			capture := surround(modem.Encode(message), tt.padding, tt.noise, rand.New(rand.NewSource(1)))
+
+			if got := New(timingConfig()).Decode(capture); string(got) != string(message) {
+				t.Errorf("Decode = %q, want %q", got, message)
+			}
+		})
+	}
+}
+
+func TestTimingRecoveryOffsets(t *testing.T) {
+	message := []byte("Timing recovery")
+	for _, offset := range []int{0, 1, 37, 120, 240, 479} {
+		modem := New(timingConfig())
+		signal := append(make([]float32, offset), modem.Encode(message)...)
+
+		if got := New(timingConfig()).Decode(signal); string(got) != string(message) {
+			t.Errorf("offset %d: Decode = %q, want %q", offset, got, message)
+		}
+	}
+}
+
+func TestTimingRecoverySilence(t *testing.T) {
+	modem := New(timingConfig())
+	if got := modem.Decode(make([]float32, 48000)); len(got) != 0 {
+		t.Errorf("Decode(silence) = %q, want nothing", got)
+	}
+}
\ No newline at end of file
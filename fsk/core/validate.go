@@ -0,0 +1,152 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MaxOrder is the largest FSK order accepted by Validate (256 tones).
+const MaxOrder = 8
+
+// Sentinel errors wrapped by ConfigError, for use with errors.Is.
+var (
+	ErrInvalidOrder      = errors.New("order out of range")
+	ErrInvalidRate       = errors.New("rate must be positive")
+	ErrInvalidFrequency  = errors.New("frequency must be positive")
+	ErrAliasing          = errors.New("tone at or above the Nyquist frequency")
+	ErrSymbolPeriod      = errors.New("symbol shorter than one sample")
+	ErrToneSpacing       = errors.New("tones too close for the baud rate")
+	ErrInvalidBT         = errors.New("bandwidth-time product must not be negative")
+	ErrInvalidDetector   = errors.New("unknown detector")
+	ErrInvalidModulation = errors.New("unknown modulation")
+	ErrInvalidThreshold  = errors.New("invalid carrier threshold")
+)
+
+// ConfigError reports a configuration field holding an invalid value.
+type ConfigError struct {
+	Field  string      // Name of the offending Config field
+	Value  interface{} // Value of the field
+	Reason string      // Human readable explanation
+	Err    error       // One of the Err* sentinels above
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s %v: %s", e.Field, e.Value, e.Reason)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that the configuration describes a modem that can work,
+// returning a *ConfigError for the first invalid field it finds.
+func (c Config) Validate() error {
+	if c.SampleRate <= 0 {
+		return &ConfigError{"SampleRate", c.SampleRate, "must be positive", ErrInvalidRate}
+	}
+	if !(c.BaudRate > 0) || math.IsInf(c.BaudRate, 0) {
+		return &ConfigError{"BaudRate", c.BaudRate, "must be positive", ErrInvalidRate}
+	}
+	if len(c.Tones) == 0 && (c.Order < 1 || c.Order > MaxOrder) {
+		return &ConfigError{"Order", c.Order, fmt.Sprintf("must be between 1 and %d", MaxOrder), ErrInvalidOrder}
+	}
+	if c.Detector < DetectorCorrelation || c.Detector > DetectorDiscriminator {
+		return &ConfigError{"Detector", int(c.Detector), "not a known DetectorType", ErrInvalidDetector}
+	}
+	if c.Modulation < ModulationFSK || c.Modulation > ModulationMSK {
+		return &ConfigError{"Modulation", int(c.Modulation), "not a known Modulation", ErrInvalidModulation}
+	}
+	if c.BT < 0 || math.IsNaN(c.BT) {
+		return &ConfigError{"BT", c.BT, "must be zero (default) or positive", ErrInvalidBT}
+	}
+
+	if int(float64(c.SampleRate)/c.BaudRate) < 1 {
+		reason := fmt.Sprintf("exceeds the sample rate of %d Hz", c.SampleRate)
+		return &ConfigError{"BaudRate", c.BaudRate, reason, ErrSymbolPeriod}
+	}
+
+	if len(c.Tones) > 0 {
+		return c.validateTones()
+	}
+
+	nyquist := float64(c.SampleRate) / 2
+	if !(c.BaseFreq > 0) || math.IsInf(c.BaseFreq, 0) {
+		return &ConfigError{"BaseFreq", c.BaseFreq, "must be positive", ErrInvalidFrequency}
+	}
+	if c.BaseFreq >= nyquist {
+		reason := fmt.Sprintf("must be below the Nyquist frequency of %g Hz", nyquist)
+		return &ConfigError{"BaseFreq", c.BaseFreq, reason, ErrAliasing}
+	}
+
+	// MSK derives its spacing from the baud rate
+	if c.Modulation != ModulationMSK {
+		if minSpacing := c.minToneSpacing(); !(c.FreqSpacing >= minSpacing) || math.IsInf(c.FreqSpacing, 0) {
+			reason := fmt.Sprintf("must be at least %g Hz at %g baud", minSpacing, c.BaudRate)
+			return &ConfigError{"FreqSpacing", c.FreqSpacing, reason, ErrToneSpacing}
+		}
+	}
+
+	tones := c.tones()
+	if highest := tones[len(tones)-1]; highest >= nyquist {
+		reason := fmt.Sprintf("puts the highest of %d tones at %g Hz, at or above the Nyquist frequency of %g Hz",
+			len(tones), highest, nyquist)
+		return &ConfigError{"FreqSpacing", c.FreqSpacing, reason, ErrAliasing}
+	}
+
+	return nil
+}
+
+// validateTones checks an explicit tone table.
+func (c Config) validateTones() error {
+	if len(c.Tones) < 2 || len(c.Tones) > 1<<MaxOrder {
+		reason := fmt.Sprintf("must list between 2 and %d tones", 1<<MaxOrder)
+		return &ConfigError{"Tones", c.Tones, reason, ErrInvalidOrder}
+	}
+
+	nyquist := float64(c.SampleRate) / 2
+	for _, tone := range c.Tones {
+		if !(tone > 0) || math.IsInf(tone, 0) {
+			return &ConfigError{"Tones", c.Tones, fmt.Sprintf("tone %g Hz is not positive", tone), ErrInvalidFrequency}
+		}
+		if tone >= nyquist {
+			reason := fmt.Sprintf("tone %g Hz is at or above the Nyquist frequency of %g Hz", tone, nyquist)
+			return &ConfigError{"Tones", c.Tones, reason, ErrAliasing}
+		}
+	}
+
+	sorted := append([]float64(nil), c.Tones...)
+	sort.Float64s(sorted)
+	minSpacing := c.minToneSpacing()
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i]-sorted[i-1] < minSpacing {
+			reason := fmt.Sprintf("tones %g and %g Hz must be at least %g Hz apart at %g baud",
+				sorted[i-1], sorted[i], minSpacing, c.BaudRate)
+			return &ConfigError{"Tones", c.Tones, reason, ErrToneSpacing}
+		}
+	}
+
+	return nil
+}
+
+// minToneSpacing returns the closest tone spacing the demodulator can tell
+// apart. The Goertzel, sliding DFT, FFT and correlation detectors measure
+// tone energy without phase coherence, so tones must be a whole baud rate
+// apart to be orthogonal over a symbol. Half the baud rate is enough for MSK
+// and for the frequency discriminator, which track the phase.
+func (c Config) minToneSpacing() float64 {
+	if c.Modulation == ModulationMSK || c.Detector == DetectorDiscriminator {
+		return c.BaudRate / 2
+	}
+	return c.BaudRate
+}
+
+// NewWithError validates config and creates a modem, returning an error
+// instead of a modem that silently produces garbage.
+func NewWithError(config Config) (*Modem, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return New(config), nil
+}
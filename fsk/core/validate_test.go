@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateToneSpacing(t *testing.T) {
+	tests := []struct {
+		name       string
+		modulation Modulation
+		detector   DetectorType
+		spacing    float64 // Multiple of the baud rate
+		valid      bool
+	}{
+		{"spacing at baud rate", ModulationFSK, DetectorGoertzel, 1, true},
+		{"FSK below baud rate", ModulationFSK, DetectorGoertzel, 0.75, false},
+		{"CPFSK below baud rate", ModulationCPFSK, DetectorCorrelation, 0.75, false},
+		{"GFSK below baud rate", ModulationGFSK, DetectorGoertzel, 0.75, false},
+		{"discriminator at half baud rate", ModulationFSK, DetectorDiscriminator, 0.5, true},
+		{"discriminator below half baud rate", ModulationFSK, DetectorDiscriminator, 0.4, false},
+		{"MSK ignores spacing", ModulationMSK, DetectorGoertzel, 0.1, true},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.Modulation = tt.modulation
+		config.Detector = tt.detector
+		config.FreqSpacing = tt.spacing * config.BaudRate
+
+		err := config.Validate()
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrToneSpacing) {
+			t.Errorf("%s: Validate() = %v, want %v", tt.name, err, ErrToneSpacing)
+		}
+	}
+}
+
+func TestValidateToneTable(t *testing.T) {
+	bell103 := Bell103Config()
+	bell103.Detector = DetectorGoertzel
+
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"KCS", KCSConfig(), true},
+		{"Bell 103", Bell103Config(), true},
+		{"Bell 103 with Goertzel", bell103, false},
+		{"close tones", Config{SampleRate: 48000, BaudRate: 100, Tones: []float64{1000, 1050}}, false},
+		{"unsorted tones a baud rate apart", Config{SampleRate: 48000, BaudRate: 100, Tones: []float64{1100, 1000}}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrToneSpacing) {
+			t.Errorf("%s: Validate() = %v, want %v", tt.name, err, ErrToneSpacing)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		field    string
+		sentinel error
+	}{
+		{"zero sample rate", Config{SampleRate: 0, BaudRate: 100, Order: 1, BaseFreq: 1000, FreqSpacing: 200}, "SampleRate", ErrInvalidRate},
+		{"negative baud rate", Config{SampleRate: 48000, BaudRate: -1, Order: 1, BaseFreq: 1000, FreqSpacing: 200}, "BaudRate", ErrInvalidRate},
+		{"baud rate above sample rate", Config{SampleRate: 8000, BaudRate: 9000, Order: 1, BaseFreq: 1000, FreqSpacing: 9000}, "BaudRate", ErrSymbolPeriod},
+		{"zero order", Config{SampleRate: 48000, BaudRate: 100, Order: 0, BaseFreq: 1000, FreqSpacing: 200}, "Order", ErrInvalidOrder},
+		{"order too large", Config{SampleRate: 48000, BaudRate: 100, Order: MaxOrder + 1, BaseFreq: 1000, FreqSpacing: 200}, "Order", ErrInvalidOrder},
+		{"zero base frequency", Config{SampleRate: 48000, BaudRate: 100, Order: 1, BaseFreq: 0, FreqSpacing: 200}, "BaseFreq", ErrInvalidFrequency},
+		{"base frequency at Nyquist", Config{SampleRate: 48000, BaudRate: 100, Order: 1, BaseFreq: 24000, FreqSpacing: 200}, "BaseFreq", ErrAliasing},
+		{"highest tone above Nyquist", Config{SampleRate: 48000, BaudRate: 100, Order: 4, BaseFreq: 20000, FreqSpacing: 300}, "FreqSpacing", ErrAliasing},
+		{"negative BT", Config{SampleRate: 48000, BaudRate: 100, Order: 1, BaseFreq: 1000, FreqSpacing: 200, BT: -1}, "BT", ErrInvalidBT},
+		{"unknown detector", Config{SampleRate: 48000, BaudRate: 100, Order: 1, BaseFreq: 1000, FreqSpacing: 200, Detector: -1}, "Detector", ErrInvalidDetector},
+		{"unknown modulation", Config{SampleRate: 48000, BaudRate: 100, Order: 1, BaseFreq: 1000, FreqSpacing: 200, Modulation: 99}, "Modulation", ErrInvalidModulation},
+	}
+
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			t.Errorf("%s: Validate() = %v, want a *ConfigError", tt.name, err)
+			continue
+		}
+		if configErr.Field != tt.field || !errors.Is(err, tt.sentinel) {
+			t.Errorf("%s: Validate() = %v, want field %s and %v", tt.name, err, tt.field, tt.sentinel)
+		}
+	}
+
+	for _, config := range []Config{DefaultConfig(), UltrasonicConfig()} {
+		if err := config.Validate(); err != nil {
+			t.Errorf("predefined config %+v: %v", config, err)
+		}
+	}
+}
\ No newline at end of file
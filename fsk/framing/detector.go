@@ -0,0 +1,149 @@
+package framing
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// Detector finds frames in a continuous stream of audio samples.
+// The modem should have TimingRecovery enabled, since frames start at
+// arbitrary offsets in the stream.
+type Detector struct {
+	modem         *core.Modem
+	config        Config
+	syncSymbols   []int
+	headerSymbols int
+	buffer        []float32
+	need          int // Buffer length required before the next scan
+}
+
+// NewDetector creates a frame detector that demodulates with modem.
+func NewDetector(modem *core.Modem, config Config) *Detector {
+	return &Detector{
+		modem:         modem,
+		config:        config,
+		syncSymbols:   modem.PackSymbols(config.SyncWord),
+		headerSymbols: modem.SymbolCount(lengthSize),
+	}
+}
+
+// Write appends samples to the stream and returns the payloads of every
+// frame completed by them.
+func (d *Detector) Write(samples []float32) [][]byte {
+	d.buffer = append(d.buffer, samples...)
+
+	var payloads [][]byte
+	for len(d.buffer) >= d.need {
+		payload, found := d.scan()
+		if !found {
+			break
+		}
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
+
+// Reset discards all buffered samples.
+func (d *Detector) Reset() {
+	d.buffer = d.buffer[:0]
+	d.need = 0
+}
+
+// scan looks for a complete frame in the buffer. When it finds one it
+// removes it from the buffer and returns its payload. Otherwise it trims the
+// buffer down to what a future frame may still need and sets d.need.
+func (d *Detector) scan() ([]byte, bool) {
+	period := d.modem.SymbolPeriod()
+	symbols := d.modem.DecodeSymbols(d.buffer)
+
+	for i := 0; i+len(d.syncSymbols) <= len(symbols); i++ {
+		if !matchSymbols(symbols[i:], d.syncSymbols) {
+			continue
+		}
+
+		headerStart := i + len(d.syncSymbols)
+		if headerStart+d.headerSymbols > len(symbols) {
+			d.wait(symbols[i].Offset, len(d.syncSymbols)+d.headerSymbols)
+			return nil, false
+		}
+
+		header := d.modem.UnpackSymbols(symbolValues(symbols[headerStart : headerStart+d.headerSymbols]))
+		length := int(binary.BigEndian.Uint16(header))
+		if length > maxPayload(d.config) {
+			continue // False sync
+		}
+
+		bodySize := lengthSize + length + crcSize
+		bodySymbols := d.modem.SymbolCount(bodySize)
+		if headerStart+bodySymbols > len(symbols) {
+			d.wait(symbols[i].Offset, len(d.syncSymbols)+bodySymbols)
+			return nil, false
+		}
+
+		body := d.modem.UnpackSymbols(symbolValues(symbols[headerStart : headerStart+bodySymbols]))[:bodySize]
+		checksum := binary.BigEndian.Uint32(body[lengthSize+length:])
+		if crc32.ChecksumIEEE(body[:lengthSize+length]) != checksum {
+			continue // Corrupted frame or false sync
+		}
+
+		end := symbols[headerStart+bodySymbols-1].Offset + period
+		d.consume(end)
+		d.need = 0
+
+		payload := make([]byte, length)
+		copy(payload, body[lengthSize:])
+		return payload, true
+	}
+
+	// No frame in progress: keep enough to hold a preamble and sync word
+	// that may have only partially arrived.
+	keep := (d.config.PreambleSymbols + len(d.syncSymbols) + 1) * period
+	if len(d.buffer) > keep {
+		d.consume(len(d.buffer) - keep)
+	}
+	d.need = len(d.buffer) + period
+	return nil, false
+}
+
+// wait keeps the frame whose sync word starts at syncOffset, together with
+// its preamble, and defers scanning until symbols more symbols after the
+// sync word have arrived.
+func (d *Detector) wait(syncOffset, symbols int) {
+	period := d.modem.SymbolPeriod()
+
+	start := syncOffset - d.config.PreambleSymbols*period
+	if start > 0 {
+		d.consume(start)
+		syncOffset -= start
+	}
+
+	d.need = syncOffset + (symbols+1)*period
+}
+
+// consume drops the first n samples from the buffer.
+func (d *Detector) consume(n int) {
+	if n > len(d.buffer) {
+		n = len(d.buffer)
+	}
+	d.buffer = append(d.buffer[:0], d.buffer[n:]...)
+}
+
+func matchSymbols(symbols []core.Symbol, pattern []int) bool {
+	for i, value := range pattern {
+		if symbols[i].Value != value {
+			return false
+		}
+	}
+	return true
+}
+
+func symbolValues(symbols []core.Symbol) []int {
+	values := make([]int, len(symbols))
+	for i, symbol := range symbols {
+		values[i] = symbol.Value
+	}
+	return values
+}
@@ -0,0 +1,113 @@
+// Package framing wraps payloads in self-delimiting frames on top of the core
+// FSK modem so that a receiver can find transmissions in a continuous stream.
+//
+// Frame layout:
+//
+//	preamble | sync word | length | payload | CRC-32
+//
+// The preamble alternates between the lowest and highest tone so the
+// receiver can lock symbol timing, the sync word marks the start of the
+// frame, the length is a big-endian uint16 and the CRC-32 (IEEE) covers the
+// length and the payload.
+package framing
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+const (
+	lengthSize = 2 // Size of the length field in bytes
+	crcSize    = 4 // Size of the CRC field in bytes
+)
+
+// ErrPayloadTooLarge is returned when a payload exceeds Config.MaxPayload.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// Config holds the framing parameters. Transmitter and receiver must agree on them.
+type Config struct {
+	PreambleSymbols int    // Alternating symbols sent before the sync word for timing lock
+	SyncWord        []byte // Marker that identifies the start of a frame
+	MaxPayload      int    // Largest payload accepted, at most 65535 bytes
+}
+
+// DefaultConfig returns the default framing configuration.
+func DefaultConfig() Config {
+	return Config{
+		PreambleSymbols: 16,
+		SyncWord:        []byte{0x2D, 0xD4},
+		MaxPayload:      1024,
+	}
+}
+
+// Framer builds frames and modulates them with a modem.
+type Framer struct {
+	modem  *core.Modem
+	config Config
+}
+
+// NewFramer creates a framer that modulates frames with modem.
+func NewFramer(modem *core.Modem, config Config) *Framer {
+	return &Framer{
+		modem:  modem,
+		config: config,
+	}
+}
+
+// Config returns the framing configuration.
+func (f *Framer) Config() Config {
+	return f.config
+}
+
+// Symbols returns the symbol sequence of a frame carrying payload.
+func (f *Framer) Symbols(payload []byte) ([]int, error) {
+	if len(payload) > maxPayload(f.config) {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrPayloadTooLarge, len(payload), maxPayload(f.config))
+	}
+
+	body := make([]byte, lengthSize+len(payload)+crcSize)
+	binary.BigEndian.PutUint16(body, uint16(len(payload)))
+	copy(body[lengthSize:], payload)
+	checksum := crc32.ChecksumIEEE(body[:lengthSize+len(payload)])
+	binary.BigEndian.PutUint32(body[lengthSize+len(payload):], checksum)
+
+	symbols := preamble(f.modem, f.config.PreambleSymbols)
+	symbols = append(symbols, f.modem.PackSymbols(f.config.SyncWord)...)
+	symbols = append(symbols, f.modem.PackSymbols(body)...)
+
+	return symbols, nil
+}
+
+// Encode builds a frame carrying payload and returns its audio signal.
+func (f *Framer) Encode(payload []byte) ([]float32, error) {
+	symbols, err := f.Symbols(payload)
+	if err != nil {
+		return nil, err
+	}
+	return f.modem.EncodeSymbols(symbols), nil
+}
+
+// preamble returns count symbols alternating between the lowest and highest tone.
+func preamble(modem *core.Modem, count int) []int {
+	highest := len(modem.Frequencies()) - 1
+
+	symbols := make([]int, count)
+	for i := range symbols {
+		if i%2 == 1 {
+			symbols[i] = highest
+		}
+	}
+	return symbols
+}
+
+// maxPayload returns the effective payload limit of config.
+func maxPayload(config Config) int {
+	if config.MaxPayload <= 0 || config.MaxPayload > 0xFFFF {
+		return 0xFFFF
+	}
+	return config.MaxPayload
+}
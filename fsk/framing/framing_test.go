@@ -0,0 +1,106 @@
+package framing
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+func TestDetectorStream(t *testing.T) {
+	modem := core.New(core.DefaultConfig())
+	framer := NewFramer(modem, DefaultConfig())
+	payloads := [][]byte{[]byte("first"), {}, []byte("a third, longer frame"), {0x2D, 0xD4, 0x00}}
+
+	// Frames separated by gaps of noise that are not whole symbols
+	rng := rand.New(rand.NewSource(1))
+	var stream []float32
+	for i, payload := range payloads {
+		gap := make([]float32, 1000+317*i)
+		for j := range gap {
+			gap[j] = float32(rng.NormFloat64() * 0.01)
+		}
+		frame, err := framer.Encode(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(append(stream, gap...), frame...)
+	}
+	stream = append(stream, make([]float32, 2*modem.SymbolPeriod())...)
+
+	for _, size := range []int{256, 480, 4096, len(stream)} {
+		detector := NewDetector(modem, DefaultConfig())
+		var got [][]byte
+		for start := 0; start < len(stream); start += size {
+			got = append(got, detector.Write(stream[start:min(start+size, len(stream))])...)
+		}
+
+		if len(got) != len(payloads) {
+			t.Fatalf("blocks of %d: %d frames, want %d: %q", size, len(got), len(payloads), got)
+		}
+		for i := range payloads {
+			if !bytes.Equal(got[i], payloads[i]) {
+				t.Errorf("blocks of %d: frame %d = %q, want %q", size, i, got[i], payloads[i])
+			}
+		}
+	}
+}
+
+func TestDetectorRejectsCorruptFrame(t *testing.T) {
+	modem := core.New(core.DefaultConfig())
+	framer := NewFramer(modem, DefaultConfig())
+	symbols, err := framer.Symbols([]byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip one payload symbol after the preamble, sync word and length
+	header := DefaultConfig().PreambleSymbols + modem.SymbolCount(len(DefaultConfig().SyncWord)+lengthSize)
+	tests := []struct {
+		name  string
+		flip  int // Symbol to change, -1 for none
+		valid bool
+	}{
+		{"intact", -1, true},
+		{"payload", header + 3, false},
+		{"checksum", len(symbols) - 1, false},
+	}
+	for _, tt := range tests {
+		damaged := append([]int(nil), symbols...)
+		if tt.flip >= 0 {
+			damaged[tt.flip] ^= 1
+		}
+		signal := append(modem.EncodeSymbols(damaged), make([]float32, 2*modem.SymbolPeriod())...)
+
+		got := NewDetector(modem, DefaultConfig()).Write(signal)
+		if (len(got) == 1) != tt.valid {
+			t.Errorf("%s: detected %q, want valid %v", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestFramerPayloadLimit(t *testing.T) {
+	tests := []struct {
+		maxPayload int
+		size       int
+		valid      bool
+	}{
+		{1024, 1024, true},
+		{1024, 1025, false},
+		{0, 0xFFFF, true},
+		{0, 0x10000, false},
+		{100000, 0x10000, false},
+	}
+
+	modem := core.New(core.DefaultConfig())
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.MaxPayload = tt.maxPayload
+		_, err := NewFramer(modem, config).Symbols(make([]byte, tt.size))
+		if (err == nil) != tt.valid || (err != nil && !errors.Is(err, ErrPayloadTooLarge)) {
+			t.Errorf("MaxPayload %d, %d bytes: Symbols() error %v, want valid %v", tt.maxPayload, tt.size, err, tt.valid)
+		}
+	}
+}
\ No newline at end of file
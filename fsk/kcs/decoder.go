@@ -0,0 +1,213 @@
+package kcs
+
+import "math"
+
+// sampleSpan is the fraction of each bit, around its middle, over which the
+// decisions are averaged. Timing is taken from the start edge only, so the
+// rest of the bit is left as margin for tape speed errors: a 12 bit
+// character stays in step up to about 3% off the nominal speed.
+const sampleSpan = 0.25
+
+// leaderBits is the steady mark, in bits, that must precede the first start
+// edge after silence or a bad character. Hiss makes short runs of mark and
+// space that look like characters, but not a leader.
+const leaderBits = 10
+
+// Decoder recovers characters from a stream of cassette audio. Like a UART
+// it waits for the mark-to-space edge of a start bit, samples every bit of
+// the character from that edge and checks the parity and stop bits, so it
+// resynchronizes on each character. Nothing is decoded until a leader of
+// steady mark has been heard, and again after silence or a bad character,
+// so tape hiss around a recording does not turn into characters.
+type Decoder struct {
+	config        Config
+	discriminator discriminator
+	samplesPerBit float64
+	frameLength   int // Soft decisions needed from a start edge to decide a character
+
+	soft    []float32 // Decisions not yet consumed
+	pos     int       // Next decision to examine in soft
+	idle    int       // Consecutive mark decisions before pos
+	carrier bool      // A leader has been heard since the last silence
+	armed   bool      // Enough mark has been seen to accept a start edge
+	errors  int
+}
+
+// NewDecoder creates a decoder for the given format.
+func NewDecoder(config Config) (*Decoder, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	samplesPerBit := config.samplesPerBit()
+	return &Decoder{
+		config:        config,
+		discriminator: newDiscriminator(config),
+		samplesPerBit: samplesPerBit,
+		frameLength:   int(math.Ceil((float64(config.frameBits())-0.5+sampleSpan/2)*samplesPerBit)) + 1,
+	}, nil
+}
+
+// Config returns the decoder's format.
+func (d *Decoder) Config() Config {
+	return d.config
+}
+
+// Write appends samples to the stream and returns the characters completed
+// by them. A character completes once the middle of its last stop bit has
+// been received.
+func (d *Decoder) Write(samples []float32) []byte {
+	d.soft = d.discriminator.process(samples, d.soft)
+
+	var data []byte
+	for {
+		value, status := d.next()
+		if status == needMore {
+			break
+		}
+		if status == decoded {
+			data = append(data, value)
+		}
+	}
+
+	d.soft = append(d.soft[:0], d.soft[d.pos:]...)
+	d.pos = 0
+	return data
+}
+
+// Errors returns the number of characters dropped because of a bad start,
+// parity or stop bit since the decoder was created or reset.
+func (d *Decoder) Errors() int {
+	return d.errors
+}
+
+// Reset discards the stream state and the error count.
+func (d *Decoder) Reset() {
+	d.discriminator.reset()
+	d.soft = d.soft[:0]
+	d.pos = 0
+	d.idle = 0
+	d.carrier = false
+	d.armed = false
+	d.errors = 0
+}
+
+// Decode returns the characters in a complete recording and the number of
+// characters dropped by framing or parity errors.
+func Decode(signal []float32, config Config) ([]byte, int, error) {
+	decoder, err := NewDecoder(config)
+	if err != nil {
+		return nil, 0, err
+	}
+	data := decoder.Write(signal)
+	return data, decoder.Errors(), nil
+}
+
+// frameStatus is the outcome of looking for the next character.
+type frameStatus int
+
+const (
+	needMore frameStatus = iota // The buffered decisions end first
+	decoded                     // A character was decoded
+	rejected                    // A start edge led to no valid character
+)
+
+// next hunts for a start edge from d.pos and decodes the character behind
+// it.
+func (d *Decoder) next() (byte, frameStatus) {
+	// A start edge counts only after half a bit of mark, so that a space
+	// bit inside a character is not taken for one, and after silence only
+	// once a leader has been heard
+	minIdle := int(d.samplesPerBit / 2)
+	leader := int(leaderBits * d.samplesPerBit)
+	for ; d.pos < len(d.soft); d.pos++ {
+		if d.soft[d.pos] > 0 {
+			d.idle++
+			if d.idle >= leader {
+				d.carrier = true
+			}
+			if d.carrier && d.idle >= minIdle {
+				d.armed = true
+			}
+			continue
+		}
+		if d.armed && d.soft[d.pos] < 0 {
+			break
+		}
+		d.idle = 0
+		if d.soft[d.pos] == 0 {
+			d.carrier = false // Silence
+			d.armed = false
+		}
+	}
+	if d.pos+d.frameLength > len(d.soft) {
+		return 0, needMore
+	}
+
+	edge := d.pos
+	bit := 0
+	if d.bit(edge, bit) >= 0 {
+		// A glitch rather than a start bit; keep hunting past it
+		d.pos = edge + minIdle
+		d.idle = 0
+		d.armed = false
+		return 0, rejected
+	}
+	bit++
+
+	var value byte
+	for i := 0; i < d.config.DataBits; i++ {
+		if d.bit(edge, bit) > 0 {
+			value |= 1 << i
+		}
+		bit++
+	}
+
+	valid := true
+	if d.config.Parity != ParityNone {
+		parity := 0
+		if d.bit(edge, bit) > 0 {
+			parity = 1
+		}
+		valid = parity == parityBit(value, d.config.DataBits, d.config.Parity)
+		bit++
+	}
+	for i := 0; i < d.config.StopBits; i++ {
+		if d.bit(edge, bit) <= 0 {
+			valid = false
+		}
+		bit++
+	}
+
+	// Resume from the middle of the last stop bit. After a valid character
+	// its second half is mark, so the next start edge is accepted at once;
+	// after a bad one a leader is needed, as it may have been noise
+	d.pos = d.middle(edge, bit-1)
+	d.idle = 0
+	d.armed = valid
+	d.carrier = valid
+	if !valid {
+		d.errors++
+		return 0, rejected
+	}
+	return value, decoded
+}
+
+// middle returns the position of the middle of bit index of the character
+// starting at edge.
+func (d *Decoder) middle(edge, index int) int {
+	return edge + int(math.Round((float64(index)+0.5)*d.samplesPerBit))
+}
+
+// bit returns the mean decision over the middle of bit index of the
+// character starting at edge.
+func (d *Decoder) bit(edge, index int) float32 {
+	center := float64(edge) + (float64(index)+0.5)*d.samplesPerBit
+	from := int(math.Round(center - sampleSpan/2*d.samplesPerBit))
+	to := int(math.Round(center + sampleSpan/2*d.samplesPerBit))
+
+	var sum float32
+	for i := from; i <= to; i++ {
+		sum += d.soft[i]
+	}
+	return sum / float32(to-from+1)
+}
\ No newline at end of file
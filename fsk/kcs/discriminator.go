@@ -0,0 +1,206 @@
+package kcs
+
+import (
+	"math"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// discriminator turns audio into one soft decision per sample: positive for
+// the mark tone, negative for the space tone and 0 for silence. The output
+// may lag the input by a fixed number of samples.
+type discriminator interface {
+	// process appends the decisions available after samples to soft.
+	process(samples []float32, soft []float32) []float32
+	reset()
+}
+
+// newDiscriminator creates the discriminator selected by config.Method.
+func newDiscriminator(config Config) discriminator {
+	if config.Method == MethodZeroCrossing {
+		return newZeroCrossingDiscriminator(config)
+	}
+	return newToneDiscriminator(config)
+}
+
+// Squelch parameters shared by both discriminators.
+const (
+	squelchRatio = 0.25  // Of the recent signal level, so hiss after a recording is silence
+	squelchFloor = 0.002 // Absolute floor, about -54 dBFS
+	levelTime    = 0.5   // Seconds for the tracked level to fall by 1/e
+)
+
+// toneDiscriminator compares the magnitudes of the two tones over a window
+// one bit long, sliding it by a fraction of a bit.
+type toneDiscriminator struct {
+	detector   core.ToneDetector
+	window     int
+	hop        int
+	buffer     []float32
+	magnitudes []float64
+	level      float64 // Recent peak of the combined magnitudes
+	decay      float64 // Level decay per hop
+}
+
+func newToneDiscriminator(config Config) *toneDiscriminator {
+	window := int(math.Round(config.samplesPerBit()))
+	hop := window / 8
+	if hop < 1 {
+		hop = 1
+	}
+	return &toneDiscriminator{
+		detector:   core.NewToneDetector(config.Detector, []float64{config.SpaceFreq, config.MarkFreq}, config.SampleRate),
+		window:     window,
+		hop:        hop,
+		magnitudes: make([]float64, 2),
+		decay:      math.Exp(-float64(hop) / (levelTime * float64(config.SampleRate))),
+	}
+}
+
+func (t *toneDiscriminator) process(samples []float32, soft []float32) []float32 {
+	t.buffer = append(t.buffer, samples...)
+
+	start := 0
+	for start+t.window <= len(t.buffer) {
+		t.detector.Magnitudes(t.buffer[start:start+t.window], t.magnitudes)
+		space, mark := t.magnitudes[0], t.magnitudes[1]
+
+		total := space + mark
+		t.level = math.Max(total, t.level*t.decay)
+		var value float32
+		if total > squelchRatio*t.level && total > squelchFloor {
+			value = float32((mark - space) / total)
+		}
+		soft = appendValue(soft, value, t.hop)
+		start += t.hop
+	}
+
+	t.buffer = append(t.buffer[:0], t.buffer[start:]...)
+	return soft
+}
+
+func (t *toneDiscriminator) reset() {
+	t.buffer = t.buffer[:0]
+	t.level = 0
+}
+
+// Zero-crossing discriminator parameters.
+const (
+	dcCutoff       = 100.0 // DC blocker corner frequency, in Hz
+	lowPassRatio   = 2.0   // Low-pass corner, in multiples of the higher tone
+	hysteresis     = 0.2   // Of the signal envelope
+	envelopeTime   = 0.005 // Seconds for the envelope to fall by 1/e
+	hysteresisMin  = 0.002 // Absolute floor of the hysteresis, about -54 dBFS
+	silentHalfSpan = 2.0   // Longest half cycle, in half periods of the lower tone
+)
+
+// zeroCrossingDiscriminator removes DC and high frequency noise, measures
+// the time between zero crossings, with hysteresis, and classifies every
+// half cycle as mark or space by comparing it with the half period of the
+// frequency midway between the tones.
+type zeroCrossingDiscriminator struct {
+	threshold   float64 // Half cycle length separating the tones, in samples
+	longest     int     // Half cycles longer than this are silence
+	shortIsMark bool
+
+	dcPole          float64
+	lastIn, lastOut float64
+	b0, b1, a1, a2  float64 // Low-pass biquad; b2 equals b0
+	x1, x2, y1, y2  float64
+	envelope        float64
+	envelopeDecay   float64
+	level           float64 // Recent peak of the envelope
+	levelDecay      float64
+	positive        bool
+	pending         int  // Samples since the last crossing
+	silent          bool // The current half cycle started in silence
+}
+
+func newZeroCrossingDiscriminator(config Config) *zeroCrossingDiscriminator {
+	rate := float64(config.SampleRate)
+	lower := math.Min(config.SpaceFreq, config.MarkFreq)
+	z := &zeroCrossingDiscriminator{
+		threshold:     rate / (config.SpaceFreq + config.MarkFreq),
+		longest:       int(silentHalfSpan * rate / (2 * lower)),
+		shortIsMark:   config.MarkFreq > config.SpaceFreq,
+		envelopeDecay: math.Exp(-1 / (envelopeTime * rate)),
+		levelDecay:    math.Exp(-1 / (levelTime * rate)),
+		dcPole:        1 - 2*math.Pi*dcCutoff/rate,
+		silent:        true,
+	}
+
+	// Butterworth low-pass well above both tones. Its delay is nearly the
+	// same for either tone, so it does not shift the bit edges, unlike a
+	// band-pass centered between them
+	corner := math.Min(lowPassRatio*math.Max(config.SpaceFreq, config.MarkFreq), 0.45*rate)
+	omega := 2 * math.Pi * corner / rate
+	alpha := math.Sin(omega) / math.Sqrt2
+	a0 := 1 + alpha
+	z.b0 = (1 - math.Cos(omega)) / 2 / a0
+	z.b1 = (1 - math.Cos(omega)) / a0
+	z.a1, z.a2 = -2*math.Cos(omega)/a0, (1-alpha)/a0
+	return z
+}
+
+func (z *zeroCrossingDiscriminator) process(samples []float32, soft []float32) []float32 {
+	for _, sample := range samples {
+		// Remove DC, then noise above the tones
+		x := float64(sample) - z.lastIn + z.dcPole*z.lastOut
+		z.lastIn, z.lastOut = float64(sample), x
+		y := z.b0*(x+z.x2) + z.b1*z.x1 - z.a1*z.y1 - z.a2*z.y2
+		z.x2, z.x1 = z.x1, x
+		z.y2, z.y1 = z.y1, y
+
+		magnitude := math.Abs(y)
+		z.envelope = math.Max(magnitude, z.envelope*z.envelopeDecay)
+		z.level = math.Max(magnitude, z.level*z.levelDecay)
+		limit := math.Max(hysteresis*z.envelope, hysteresisMin)
+
+		z.pending++
+		crossed := (z.positive && y < -limit) || (!z.positive && y > limit)
+		squelched := z.envelope < squelchRatio*z.level || z.envelope < squelchFloor
+		switch {
+		case crossed:
+			z.positive = !z.positive
+			var value float32
+			if !z.silent && !squelched {
+				value = -1
+				if (float64(z.pending) < z.threshold) == z.shortIsMark {
+					value = 1
+				}
+			}
+			soft = appendValue(soft, value, z.pending)
+			z.pending = 0
+			z.silent = squelched
+		case z.pending > z.longest:
+			soft = appendValue(soft, 0, z.pending)
+			z.pending = 0
+			z.silent = true
+		}
+	}
+	return soft
+}
+
+func (z *zeroCrossingDiscriminator) reset() {
+	*z = zeroCrossingDiscriminator{
+		threshold:     z.threshold,
+		longest:       z.longest,
+		shortIsMark:   z.shortIsMark,
+		dcPole:        z.dcPole,
+		b0:            z.b0,
+		b1:            z.b1,
+		a1:            z.a1,
+		a2:            z.a2,
+		envelopeDecay: z.envelopeDecay,
+		levelDecay:    z.levelDecay,
+		silent:        true,
+	}
+}
+
+// appendValue appends count copies of value.
+func appendValue(soft []float32, value float32, count int) []float32 {
+	for i := 0; i < count; i++ {
+		soft = append(soft, value)
+	}
+	return soft
+}
\ No newline at end of file
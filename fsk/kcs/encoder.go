@@ -0,0 +1,86 @@
+package kcs
+
+import (
+	"math"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// Encoder generates cassette audio for blocks of data.
+type Encoder struct {
+	config Config
+	modem  *core.Modem
+}
+
+// NewEncoder creates an encoder for the given format.
+func NewEncoder(config Config) (*Encoder, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Encoder{
+		config: config,
+		modem:  core.New(config.modemConfig()),
+	}, nil
+}
+
+// Config returns the encoder's format.
+func (e *Encoder) Config() Config {
+	return e.config
+}
+
+// Bits returns the bit sequence of data: the leader, every character with
+// its start, parity and stop bits, and the trailer.
+func (e *Encoder) Bits(data []byte) []int {
+	leader := e.idleBits(e.config.Leader.Seconds())
+	trailer := e.idleBits(e.config.Trailer.Seconds())
+
+	bits := make([]int, 0, leader+len(data)*e.config.frameBits()+trailer)
+	bits = appendIdle(bits, leader)
+	for _, value := range data {
+		bits = e.appendCharacter(bits, value)
+	}
+	return appendIdle(bits, trailer)
+}
+
+// Encode returns the audio for data, including leader and trailer. The
+// tones keep a continuous phase, so every bit starts on a zero crossing.
+func (e *Encoder) Encode(data []byte) []float32 {
+	return e.modem.EncodeSymbols(e.Bits(data))
+}
+
+// Encode returns the audio for data in the given format.
+func Encode(data []byte, config Config) ([]float32, error) {
+	encoder, err := NewEncoder(config)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.Encode(data), nil
+}
+
+// appendCharacter appends the asynchronous frame of one character.
+func (e *Encoder) appendCharacter(bits []int, value byte) []int {
+	bits = append(bits, 0) // Start bit
+	for i := 0; i < e.config.DataBits; i++ {
+		bits = append(bits, int(value>>i)&1)
+	}
+	if e.config.Parity != ParityNone {
+		bits = append(bits, parityBit(value, e.config.DataBits, e.config.Parity))
+	}
+	return appendIdle(bits, e.config.StopBits)
+}
+
+// idleBits returns the number of mark bits lasting seconds.
+func (e *Encoder) idleBits(seconds float64) int {
+	if seconds <= 0 {
+		return 0
+	}
+	return int(math.Ceil(seconds * e.config.BaudRate))
+}
+
+// appendIdle appends count mark bits.
+func appendIdle(bits []int, count int) []int {
+	for i := 0; i < count; i++ {
+		bits = append(bits, 1)
+	}
+	return bits
+}
\ No newline at end of file
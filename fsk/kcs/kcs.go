@@ -0,0 +1,194 @@
+// Package kcs encodes and decodes the Kansas City Standard cassette format
+// and its 1200 baud CUTS variant on top of the FSK core.
+//
+// Each bit is a whole number of cycles of one of two tones: at 300 baud a
+// 0 is 4 cycles of 1200 Hz and a 1 is 8 cycles of 2400 Hz, at 1200 baud a 0
+// is one cycle of 1200 Hz and a 1 two cycles of 2400 Hz. Bytes are sent
+// asynchronously, like a serial line:
+//
+//	start bit (0) | data bits, LSB first | optional parity | stop bits (1)
+//
+// The line idles on the 2400 Hz mark tone, and a recording starts with a
+// leader of mark tone. The decoder finds every byte by its start bit, so it
+// follows the speed drift of a real tape.
+package kcs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// Parity selects the parity bit sent after the data bits.
+type Parity int
+
+const (
+	ParityNone Parity = iota // No parity bit
+	ParityEven               // Even number of ones across data and parity
+	ParityOdd                // Odd number of ones across data and parity
+)
+
+// String returns the parity name.
+func (p Parity) String() string {
+	switch p {
+	case ParityNone:
+		return "none"
+	case ParityEven:
+		return "even"
+	case ParityOdd:
+		return "odd"
+	}
+	return "unknown"
+}
+
+// Method selects how the decoder tells the two tones apart.
+type Method int
+
+const (
+	// MethodTone measures both tones with a core.ToneDetector over a
+	// sliding window one bit long. It is the most robust against noise.
+	MethodTone Method = iota
+
+	// MethodZeroCrossing times the half cycles between zero crossings, as
+	// the original cassette interfaces did. It copes best with clipped or
+	// square-wave recordings and with strong amplitude changes.
+	MethodZeroCrossing
+)
+
+// String returns the method name.
+func (m Method) String() string {
+	switch m {
+	case MethodTone:
+		return "tone"
+	case MethodZeroCrossing:
+		return "zero-crossing"
+	}
+	return "unknown"
+}
+
+// Sentinel errors returned by Config.Validate, for use with errors.Is.
+var (
+	ErrInvalidTiming = errors.New("invalid baud rate or sample rate")
+	ErrInvalidTones  = errors.New("invalid tones")
+	ErrInvalidFrame  = errors.New("invalid character framing")
+)
+
+// Config holds the cassette format parameters.
+type Config struct {
+	BaudRate   float64 // Bits per second
+	SampleRate int     // Audio sample rate
+	SpaceFreq  float64 // Tone of a 0 bit, in Hz
+	MarkFreq   float64 // Tone of a 1 bit and of the idle line, in Hz
+	DataBits   int     // Data bits per character, 5 to 8
+	Parity     Parity
+	StopBits   int           // Stop bits per character, 1 or 2
+	Leader     time.Duration // Mark tone before the first character
+	Trailer    time.Duration // Mark tone after the last character
+
+	// Method selects the tone discrimination used by the decoder, and
+	// Detector the core detector used by MethodTone.
+	Method   Method
+	Detector core.DetectorType
+}
+
+// KCSConfig returns the original Kansas City Standard: 300 baud, 0 as 4
+// cycles of 1200 Hz, 1 as 8 cycles of 2400 Hz, eight data bits, no parity
+// and two stop bits after a five second leader.
+func KCSConfig() Config {
+	return Config{
+		BaudRate:   300,
+		SampleRate: 48000,
+		SpaceFreq:  1200,
+		MarkFreq:   2400,
+		DataBits:   8,
+		Parity:     ParityNone,
+		StopBits:   2,
+		Leader:     5 * time.Second,
+		Trailer:    100 * time.Millisecond,
+		Method:     MethodTone,
+		Detector:   core.DetectorGoertzel,
+	}
+}
+
+// CUTSConfig returns the 1200 baud CUTS variant: 0 as one cycle of
+// 1200 Hz, 1 as two cycles of 2400 Hz, eight data bits, no parity and one
+// stop bit, as used by the BBC Micro. MSX tapes use the same tones with two
+// stop bits; a decoder set for one stop bit reads both.
+func CUTSConfig() Config {
+	config := KCSConfig()
+	config.BaudRate = 1200
+	config.StopBits = 1
+	return config
+}
+
+// Validate checks that the configuration describes a format that can be
+// encoded and decoded.
+func (c Config) Validate() error {
+	if !(c.BaudRate > 0) || c.SampleRate <= 0 {
+		return fmt.Errorf("%w: %v baud at %d Hz", ErrInvalidTiming, c.BaudRate, c.SampleRate)
+	}
+	if float64(c.SampleRate) < 4*c.BaudRate {
+		return fmt.Errorf("%w: fewer than 4 samples per bit", ErrInvalidTiming)
+	}
+	if !(c.SpaceFreq > 0) || !(c.MarkFreq > 0) || c.SpaceFreq == c.MarkFreq {
+		return fmt.Errorf("%w: space %v Hz, mark %v Hz", ErrInvalidTones, c.SpaceFreq, c.MarkFreq)
+	}
+	nyquist := float64(c.SampleRate) / 2
+	if c.SpaceFreq >= nyquist || c.MarkFreq >= nyquist {
+		return fmt.Errorf("%w: tones must be below %v Hz", ErrInvalidTones, nyquist)
+	}
+	if c.DataBits < 5 || c.DataBits > 8 {
+		return fmt.Errorf("%w: %d data bits", ErrInvalidFrame, c.DataBits)
+	}
+	if c.StopBits < 1 || c.StopBits > 2 {
+		return fmt.Errorf("%w: %d stop bits", ErrInvalidFrame, c.StopBits)
+	}
+	if c.Parity < ParityNone || c.Parity > ParityOdd {
+		return fmt.Errorf("%w: parity %d", ErrInvalidFrame, int(c.Parity))
+	}
+	if c.Method < MethodTone || c.Method > MethodZeroCrossing {
+		return fmt.Errorf("unknown decoding method %d", int(c.Method))
+	}
+	return nil
+}
+
+// samplesPerBit returns the exact, possibly fractional, bit length.
+func (c Config) samplesPerBit() float64 {
+	return float64(c.SampleRate) / c.BaudRate
+}
+
+// frameBits returns the number of bits in a character, start to last stop.
+func (c Config) frameBits() int {
+	bits := 1 + c.DataBits + c.StopBits
+	if c.Parity != ParityNone {
+		bits++
+	}
+	return bits
+}
+
+// modemConfig returns the core configuration that synthesizes the two
+// tones: symbol 0 is the space tone and symbol 1 the mark tone. Tape
+// interfaces switch tones without a phase jump, hence CPFSK.
+func (c Config) modemConfig() core.Config {
+	config := core.KCSConfig()
+	config.Tones = []float64{c.SpaceFreq, c.MarkFreq}
+	config.BaudRate = c.BaudRate
+	config.SampleRate = c.SampleRate
+	config.Detector = c.Detector
+	config.Modulation = core.ModulationCPFSK
+	return config
+}
+
+// parityBit returns the parity bit for value, which must not be ParityNone.
+func parityBit(value byte, dataBits int, parity Parity) int {
+	ones := 0
+	for i := 0; i < dataBits; i++ {
+		ones += int(value>>i) & 1
+	}
+	if parity == ParityOdd {
+		ones++
+	}
+	return ones & 1
+}
\ No newline at end of file
@@ -0,0 +1,230 @@
+package kcs
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// configs returns every format and decoding method combination under test.
+func configs() map[string]Config {
+	configs := make(map[string]Config)
+	for name, config := range map[string]Config{"KCS": KCSConfig(), "CUTS": CUTSConfig()} {
+		config.Leader = 500 * time.Millisecond
+		config.Method = MethodTone
+		configs[name+"/tone"] = config
+		config.Method = MethodZeroCrossing
+		configs[name+"/zero-crossing"] = config
+	}
+	return configs
+}
+
+// testData returns every byte value.
+func testData() []byte {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	framings := []struct {
+		name     string
+		dataBits int
+		parity   Parity
+		stopBits int
+	}{
+		{"8N1", 8, ParityNone, 1},
+		{"8N2", 8, ParityNone, 2},
+		{"7E1", 7, ParityEven, 1},
+		{"7O2", 7, ParityOdd, 2},
+		{"5N1", 5, ParityNone, 1},
+	}
+
+	for name, config := range configs() {
+		for _, framing := range framings {
+			config := config
+			config.DataBits, config.Parity, config.StopBits = framing.dataBits, framing.parity, framing.stopBits
+			data := testData()
+			for i := range data {
+				data[i] &= 1<<framing.dataBits - 1
+			}
+
+			t.Run(name+"/"+framing.name, func(t *testing.T) {
+				signal, err := Encode(data, config)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, errors, err := Decode(signal, config)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) || errors != 0 {
+					t.Errorf("Decode = %d bytes with %d errors, want %d bytes", len(got), errors, len(data))
+				}
+			})
+		}
+	}
+}
+
+func TestTapeSpeed(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+	for name, config := range configs() {
+		for _, speed := range []float64{0.98, 1.02} {
+			// A tape played fast raises the tones and the baud rate alike
+			played := config
+			played.BaudRate *= speed
+			played.SpaceFreq *= speed
+			played.MarkFreq *= speed
+			signal, err := Encode(data, played)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, _, _ := Decode(signal, config); !bytes.Equal(got, data) {
+				t.Errorf("%s at %v speed: Decode = %q, want %q", name, speed, got, data)
+			}
+		}
+	}
+}
+
+func TestNoisyRecording(t *testing.T) {
+	message := []byte("HELLO")
+	tests := []struct {
+		noise        float64
+		zeroCrossing bool // Also run with MethodZeroCrossing
+	}{
+		{0.02, true},
+		{0.05, true},
+		{0.1, false},
+	}
+
+	for name, config := range configs() {
+		signal, err := Encode(message, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			if config.Method == MethodZeroCrossing && !tt.zeroCrossing {
+				continue
+			}
+
+			// Tape hiss on its own before and after the recording
+			for seed := int64(0); seed < 5; seed++ {
+				rng := rand.New(rand.NewSource(seed))
+				hiss := make([]float32, config.SampleRate)
+				recording := append(append(hiss, signal...), hiss...)
+				for i := range recording {
+					if i >= len(hiss) && i < len(hiss)+len(signal) {
+						recording[i] *= 0.5
+					}
+					recording[i] += float32(rng.NormFloat64() * tt.noise)
+				}
+
+				if got, _, _ := Decode(recording, config); !bytes.Equal(got, message) {
+					t.Errorf("%s, noise %v, seed %d: Decode = %q, want %q", name, tt.noise, seed, got, message)
+				}
+			}
+		}
+	}
+}
+
+func TestLeaderAfterSilence(t *testing.T) {
+	first, second := []byte("FIRST"), []byte("SECOND")
+	for name, config := range configs() {
+		bare := config
+		bare.Leader = 0
+
+		tests := []struct {
+			name   string
+			second Config
+			want   []byte
+		}{
+			{"with leader", config, append(append([]byte{}, first...), second...)},
+			{"without leader", bare, first},
+		}
+		for _, tt := range tests {
+			a, err := Encode(first, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Encode(second, tt.second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recording := append(append(a, make([]float32, config.SampleRate/2)...), b...)
+
+			if got, _, _ := Decode(recording, config); !bytes.Equal(got, tt.want) {
+				t.Errorf("%s, second block %s: Decode = %q, want %q", name, tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecoderStreaming(t *testing.T) {
+	data := testData()
+	for name, config := range configs() {
+		signal, err := Encode(data, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, size := range []int{1, 100, 4096} {
+			decoder, err := NewDecoder(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []byte
+			for start := 0; start < len(signal); start += size {
+				got = append(got, decoder.Write(signal[start:min(start+size, len(signal))])...)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s in blocks of %d: decoded %d bytes, want %d", name, size, len(got), len(data))
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	noBaudRate := KCSConfig()
+	noBaudRate.BaudRate = 0
+	fast := KCSConfig()
+	fast.BaudRate = 20000
+	equalTones := KCSConfig()
+	equalTones.MarkFreq = equalTones.SpaceFreq
+	aliased := KCSConfig()
+	aliased.MarkFreq = 30000
+	fourBits := KCSConfig()
+	fourBits.DataBits = 4
+	threeStopBits := KCSConfig()
+	threeStopBits.StopBits = 3
+	unknownParity := KCSConfig()
+	unknownParity.Parity = 5
+	unknownMethod := KCSConfig()
+	unknownMethod.Method = 5
+
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"KCS", KCSConfig(), true},
+		{"CUTS", CUTSConfig(), true},
+		{"no baud rate", noBaudRate, false},
+		{"too few samples per bit", fast, false},
+		{"equal tones", equalTones, false},
+		{"tone above Nyquist", aliased, false},
+		{"4 data bits", fourBits, false},
+		{"3 stop bits", threeStopBits, false},
+		{"unknown parity", unknownParity, false},
+		{"unknown method", unknownMethod, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
\ No newline at end of file
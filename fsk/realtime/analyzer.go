@@ -0,0 +1,354 @@
+package realtime
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// Channel analyzer defaults.
+const (
+	DefaultOccupancyThreshold = 10.0             // dB above the noise floor that counts as occupied
+	DefaultOccupancyWindow    = 10 * time.Second // Time over which occupancy is averaged
+	DefaultChannelTones       = 4                // Tones assumed for a watched channel (order 2)
+)
+
+const (
+	analyzerSampleRate = 48000
+	analyzerFFTSize    = 2048                   // 23.4 Hz bins at 48 kHz
+	analyzerAveraging  = 250 * time.Millisecond // Time constant of the reported power
+	floorRise          = 1.0                    // dB per second the noise floor may rise
+	floorCeiling       = 6.0                    // dB above the median floor a bin floor may reach
+	minimumDBFS        = -200.0                 // Reported for silence
+)
+
+// BinStats describes one FFT bin.
+type BinStats struct {
+	Frequency  float64 // Centre frequency in Hz
+	Power      float64 // Averaged power in dBFS
+	NoiseFloor float64 // Noise floor in dBFS
+	Occupancy  float64 // Share of the window spent above the threshold, 0 to 1
+}
+
+// ChannelStats describes the band of a watched channel.
+type ChannelStats struct {
+	Channel    ChannelConfig
+	Tones      int     // Tones the band was sized for
+	Low, High  float64 // Band edges in Hz
+	Available  bool    // False when the band reaches the Nyquist frequency
+	Power      float64 // Power in the band, dBFS
+	NoiseFloor float64 // Noise power in the band, dBFS
+	SNR        float64 // Power above the noise floor, dB
+	Occupancy  float64 // Share of the window spent above the threshold, 0 to 1
+}
+
+// SpectrumSnapshot is a consistent copy of the analyzer's measurements.
+type SpectrumSnapshot struct {
+	Time       time.Time // When the last block was analysed
+	Blocks     int       // Blocks analysed since the start
+	BinWidth   float64   // Bin spacing in Hz
+	NoiseFloor float64   // Median noise floor across bins, dBFS
+	Bins       []BinStats
+	Channels   []ChannelStats
+}
+
+// watchedChannel is a channel band with its running occupancy.
+type watchedChannel struct {
+	config    ChannelConfig
+	tones     int
+	low, high int // First and last bin of the band
+	available bool
+	occupancy float64
+}
+
+// ChannelAnalyzer measures the spectrum of captured audio. Every block is
+// transformed with a Hann-windowed FFT; the analyzer tracks the averaged
+// power, noise floor and occupancy of each bin and of the band of each
+// watched channel.
+//
+// A bin's noise floor follows its power down at once and rises at most
+// 1 dB per second, never more than 6 dB above the median floor of all
+// bins, so a long transmission is not mistaken for noise. A bin or band is
+// occupied while its power is Threshold dB above its floor; occupancy is
+// that share, exponentially averaged over the occupancy window.
+type ChannelAnalyzer struct {
+	source     AudioSource
+	owned      bool // The analyzer created source
+	sampleRate int
+	spectrum   *core.Spectrum
+	mu         sync.RWMutex
+	block      []float32 // Samples of the block being filled
+	frame      []float64 // Power of the last block
+	power      []float64 // Averaged power per bin
+	floor      []float64 // Noise floor per bin
+	occupancy  []float64 // Occupancy per bin
+	sorted     []float64 // Scratch buffer for the median
+	median     float64   // Median noise floor
+	channels   []*watchedChannel
+	threshold  float64 // Occupancy threshold as a power ratio
+	window     time.Duration
+	blocks     int
+	updated    time.Time
+	running    bool
+}
+
+// NewChannelAnalyzer creates a channel analyzer on the default capture
+// device at 48 kHz, watching the predefined channels.
+func NewChannelAnalyzer() *ChannelAnalyzer {
+	ca := NewChannelAnalyzerWithSource(nil, analyzerSampleRate)
+	ca.owned = true
+	return ca
+}
+
+// NewChannelAnalyzerWithSource creates a channel analyzer reading source at
+// sampleRate, watching the predefined channels.
+func NewChannelAnalyzerWithSource(source AudioSource, sampleRate int) *ChannelAnalyzer {
+	spectrum := core.NewSpectrum(analyzerFFTSize, sampleRate)
+	bins := spectrum.Bins()
+
+	ca := &ChannelAnalyzer{
+		source:     source,
+		sampleRate: sampleRate,
+		spectrum:   spectrum,
+		frame:      make([]float64, bins),
+		power:      make([]float64, bins),
+		floor:      make([]float64, bins),
+		occupancy:  make([]float64, bins),
+		sorted:     make([]float64, bins),
+		window:     DefaultOccupancyWindow,
+	}
+	ca.SetOccupancy(DefaultOccupancyThreshold, DefaultOccupancyWindow)
+	for _, channel := range PredefinedChannels() {
+		ca.Watch(channel, DefaultChannelTones)
+	}
+	return ca
+}
+
+// Watch adds a channel whose band spans tones tones, replacing any watched
+// channel with the same ID.
+func (ca *ChannelAnalyzer) Watch(channel ChannelConfig, tones int) {
+	if tones < 1 {
+		tones = 1
+	}
+	low := channel.BaseFreq - channel.FreqSpacing/2
+	high := channel.BaseFreq + channel.FreqSpacing*float64(tones-1) + channel.FreqSpacing/2
+
+	watched := &watchedChannel{
+		config:    channel,
+		tones:     tones,
+		low:       ca.spectrum.Bin(low),
+		high:      ca.spectrum.Bin(high),
+		available: low > 0 && high < float64(ca.sampleRate)/2,
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	for i, existing := range ca.channels {
+		if existing.config.ID == channel.ID {
+			ca.channels[i] = watched
+			return
+		}
+	}
+	ca.channels = append(ca.channels, watched)
+}
+
+// SetOccupancy sets the level above the noise floor, in dB, that counts as
+// occupied and the window over which occupancy is averaged.
+func (ca *ChannelAnalyzer) SetOccupancy(threshold float64, window time.Duration) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	ca.threshold = math.Pow(10, threshold/10)
+	if window > 0 {
+		ca.window = window
+	}
+}
+
+// StartAnalysis begins monitoring channel activity
+func (ca *ChannelAnalyzer) StartAnalysis() error {
+	if ca.source == nil {
+		source, err := NewMalgoSource()
+		if err != nil {
+			return err
+		}
+		ca.source = source
+	}
+
+	if err := ca.source.Start(ca.sampleRate, ca.onSamples); err != nil {
+		return err
+	}
+
+	ca.mu.Lock()
+	ca.running = true
+	ca.mu.Unlock()
+	return nil
+}
+
+func (ca *ChannelAnalyzer) onSamples(samples []float32) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	size := ca.spectrum.Size()
+	for len(samples) > 0 {
+		n := size - len(ca.block)
+		if n > len(samples) {
+			n = len(samples)
+		}
+		ca.block = append(ca.block, samples[:n]...)
+		samples = samples[n:]
+
+		if len(ca.block) == size {
+			ca.analyze()
+			ca.block = ca.block[:0]
+		}
+	}
+}
+
+// analyze updates every measurement with the full block. ca.mu must be held.
+func (ca *ChannelAnalyzer) analyze() {
+	ca.spectrum.Power(ca.block, ca.frame)
+
+	duration := float64(ca.spectrum.Size()) / float64(ca.sampleRate)
+	smoothing := 1 - math.Exp(-duration/analyzerAveraging.Seconds())
+	averaging := 1 - math.Exp(-duration/ca.window.Seconds())
+	rise := math.Pow(10, floorRise*duration/10)
+
+	first := ca.blocks == 0
+	for k, p := range ca.frame {
+		if first {
+			ca.power[k] = p
+		} else {
+			ca.power[k] += smoothing * (p - ca.power[k])
+		}
+	}
+
+	copy(ca.sorted, ca.power)
+	sort.Float64s(ca.sorted)
+	ca.median = ca.sorted[len(ca.sorted)/2]
+	ceiling := ca.median * math.Pow(10, floorCeiling/10)
+
+	for k, p := range ca.power {
+		floor := ca.floor[k] * rise
+		if first || p < floor {
+			floor = p
+		}
+		if floor > ceiling {
+			floor = ceiling
+		}
+		ca.floor[k] = floor
+		ca.occupancy[k] += averaging * (ca.occupied(p, floor) - ca.occupancy[k])
+	}
+
+	for _, channel := range ca.channels {
+		if !channel.available {
+			continue
+		}
+		power, floor := ca.band(channel)
+		channel.occupancy += averaging * (ca.occupied(power, floor) - channel.occupancy)
+	}
+
+	ca.blocks++
+	ca.updated = time.Now()
+}
+
+// occupied returns 1 when power is above the threshold over floor.
+func (ca *ChannelAnalyzer) occupied(power, floor float64) float64 {
+	if power > floor*ca.threshold {
+		return 1
+	}
+	return 0
+}
+
+// band returns the power and noise floor of a channel's band.
+func (ca *ChannelAnalyzer) band(channel *watchedChannel) (power, floor float64) {
+	for k := channel.low; k <= channel.high; k++ {
+		power += ca.power[k]
+		floor += ca.floor[k]
+	}
+	enbw := ca.spectrum.NoiseBandwidth()
+	return power / enbw, floor / enbw
+}
+
+// Snapshot returns the current measurements of every bin and watched
+// channel.
+func (ca *ChannelAnalyzer) Snapshot() SpectrumSnapshot {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+
+	snapshot := SpectrumSnapshot{
+		Time:       ca.updated,
+		Blocks:     ca.blocks,
+		BinWidth:   ca.spectrum.BinWidth(),
+		NoiseFloor: dbfs(ca.median),
+		Bins:       make([]BinStats, len(ca.power)),
+		Channels:   make([]ChannelStats, 0, len(ca.channels)),
+	}
+	for k := range ca.power {
+		snapshot.Bins[k] = BinStats{
+			Frequency:  ca.spectrum.Frequency(k),
+			Power:      dbfs(ca.power[k]),
+			NoiseFloor: dbfs(ca.floor[k]),
+			Occupancy:  ca.occupancy[k],
+		}
+	}
+	for _, channel := range ca.channels {
+		stats := ChannelStats{
+			Channel:   channel.config,
+			Tones:     channel.tones,
+			Low:       channel.config.BaseFreq - channel.config.FreqSpacing/2,
+			High:      channel.config.BaseFreq + channel.config.FreqSpacing*(float64(channel.tones)-0.5),
+			Available: channel.available,
+			Occupancy: channel.occupancy,
+		}
+		if channel.available && ca.blocks > 0 {
+			power, floor := ca.band(channel)
+			stats.Power = dbfs(power)
+			stats.NoiseFloor = dbfs(floor)
+			stats.SNR = stats.Power - stats.NoiseFloor
+		}
+		snapshot.Channels = append(snapshot.Channels, stats)
+	}
+	return snapshot
+}
+
+// GetChannelActivity returns the occupancy, from 0 to 1, of every watched
+// channel below the Nyquist frequency, keyed by its base frequency.
+func (ca *ChannelAnalyzer) GetChannelActivity() map[float64]float64 {
+	ca.mu.RLock()
+	defer ca.mu.RUnlock()
+
+	activity := make(map[float64]float64)
+	for _, channel := range ca.channels {
+		if channel.available {
+			activity[channel.config.BaseFreq] = channel.occupancy
+		}
+	}
+	return activity
+}
+
+// Stop stops the channel analysis
+func (ca *ChannelAnalyzer) Stop() {
+	ca.mu.Lock()
+	ca.running = false
+	ca.mu.Unlock()
+
+	if ca.source != nil {
+		ca.source.Stop()
+		if ca.owned {
+			ca.source.Close()
+			ca.source = nil
+		}
+	}
+}
+
+// dbfs converts a power to dB relative to a full-scale sine.
+func dbfs(power float64) float64 {
+	if power <= 0 {
+		return minimumDBFS
+	}
+	return math.Max(10*math.Log10(power/0.5), minimumDBFS)
+}
\ No newline at end of file
@@ -0,0 +1,74 @@
+package realtime
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+func TestChannelAnalyzerLoopback(t *testing.T) {
+	const sampleRate = 48000
+	busy := PredefinedChannels()[3] // 18 kHz
+
+	loopback := NewLoopback()
+	analyzer := NewChannelAnalyzerWithSource(loopback.Source(), sampleRate)
+	analyzer.SetOccupancy(DefaultOccupancyThreshold, time.Second)
+	if err := analyzer.StartAnalysis(); err != nil {
+		t.Fatal(err)
+	}
+	defer analyzer.Stop()
+
+	// One second of noise to settle the noise floor, then three seconds of
+	// a chat transmission on the busy channel. The noise, at about -30 dBFS, also
+	// covers the spectral splatter of the transmission in the next channel.
+	config := core.UltrasonicConfig()
+	config.BaseFreq = busy.BaseFreq
+	config.FreqSpacing = busy.FreqSpacing
+	message := make([]byte, int(3*config.BaudRate)*config.Order/8)
+	for i := range message {
+		message[i] = byte(i * 37)
+	}
+	signal := append(make([]float32, sampleRate), core.New(config).Encode(message)...)
+	rng := rand.New(rand.NewSource(1))
+	for i := range signal {
+		signal[i] += float32(rng.NormFloat64() * 0.03)
+	}
+
+	sink := loopback.Sink()
+	if err := sink.Start(sampleRate); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(signal); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Drain(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := analyzer.Snapshot()
+	if want := (len(signal) + sampleRate/4) / analyzerFFTSize; snapshot.Blocks < want-1 {
+		t.Errorf("Snapshot().Blocks = %d, want about %d", snapshot.Blocks, want)
+	}
+	activity := analyzer.GetChannelActivity()
+	for _, channel := range snapshot.Channels {
+		if !channel.Available {
+			if _, ok := activity[channel.Channel.BaseFreq]; ok {
+				t.Errorf("%s: unavailable channel reported by GetChannelActivity", channel.Channel.Name)
+			}
+			continue
+		}
+
+		isBusy := channel.Channel.ID == busy.ID
+		if (channel.Occupancy > 0.5) != isBusy {
+			t.Errorf("%s: occupancy %.2f, want busy %v", channel.Channel.Name, channel.Occupancy, isBusy)
+		}
+		if (channel.SNR > DefaultOccupancyThreshold) != isBusy {
+			t.Errorf("%s: SNR %.1f dB, want busy %v", channel.Channel.Name, channel.SNR, isBusy)
+		}
+		if got := activity[channel.Channel.BaseFreq]; got != channel.Occupancy {
+			t.Errorf("%s: GetChannelActivity() = %.2f, Snapshot() occupancy %.2f", channel.Channel.Name, got, channel.Occupancy)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,74 @@
+package realtime
+
+import "encoding/binary"
+
+// sourceBlockSize is the number of samples per block delivered by sources
+// that are not driven by a sound card.
+const sourceBlockSize = 1024
+
+// AudioSource captures mono audio for receivers and chat sessions.
+type AudioSource interface {
+	// Start begins capturing at sampleRate and passes every captured block
+	// to onSamples. onSamples may run on another goroutine and must not keep
+	// the slice after it returns.
+	Start(sampleRate int, onSamples func(samples []float32)) error
+
+	// Stop ends capture. Once it returns onSamples is no longer called.
+	Stop() error
+
+	// Close stops capture and releases the source.
+	Close() error
+}
+
+// Unpaced is implemented by sources that deliver audio as fast as it is
+// consumed rather than in real time, such as files, pipes and the loopback.
+// Their onSamples callback may block; receivers use that to wait for
+// buffer space instead of dropping samples.
+type Unpaced interface {
+	AudioSource
+
+	// Unpaced marks the source; it does nothing.
+	Unpaced()
+}
+
+// AudioSink plays mono audio for transmitters and chat sessions.
+type AudioSink interface {
+	// Start prepares playback at sampleRate.
+	Start(sampleRate int) error
+
+	// Write queues samples for playback without waiting for them to play.
+	Write(samples []float32) error
+
+	// Drain blocks until every queued sample has been played.
+	Drain() error
+
+	// Stop ends playback and discards samples still queued.
+	Stop() error
+
+	// Close stops playback and releases the sink.
+	Close() error
+}
+
+// pcm16ToFloat appends the little-endian 16-bit samples in src to dst as
+// float32 values in [-1, 1].
+func pcm16ToFloat(dst []float32, src []byte) []float32 {
+	for i := 0; i+1 < len(src); i += 2 {
+		sample := int16(binary.LittleEndian.Uint16(src[i : i+2]))
+		dst = append(dst, float32(sample)/32767.0)
+	}
+	return dst
+}
+
+// floatToPCM16 writes src into dst as little-endian 16-bit samples,
+// clamping to [-1, 1]. dst must hold 2 bytes per sample.
+func floatToPCM16(dst []byte, src []float32) {
+	for i, floatSample := range src {
+		if floatSample > 1.0 {
+			floatSample = 1.0
+		}
+		if floatSample < -1.0 {
+			floatSample = -1.0
+		}
+		binary.LittleEndian.PutUint16(dst[i*2:], uint16(int16(floatSample*32767)))
+	}
+}
\ No newline at end of file
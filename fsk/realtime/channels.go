@@ -1,7 +1,10 @@
 package realtime
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -16,7 +19,16 @@ type ChannelConfig struct {
 	Name        string  // Human-readable channel name
 }
 
-// PredefinedChannels returns common ultrasonic channels
+// PredefinedChannels returns common ultrasonic channels. With the 4 tones
+// of order 2, channels 2 and 3 reach above 24 kHz and need a sample rate of
+// 96 kHz; at 48 kHz only channels 1, 4 and 5 can be joined:
+//
+//	ID  Base     Spacing  Minimum sample rate
+//	1   22 kHz   500 Hz   48 kHz
+//	2   24 kHz   500 Hz   96 kHz
+//	3   26 kHz   500 Hz   96 kHz
+//	4   18 kHz   400 Hz   48 kHz
+//	5   20 kHz   400 Hz   48 kHz
 func PredefinedChannels() []ChannelConfig {
 	return []ChannelConfig{
 		{ID: 1, BaseFreq: 22000, FreqSpacing: 500, Name: "Channel 1 (22kHz)"},
@@ -47,54 +59,238 @@ func DuplexChannels() map[string]struct {
 	}
 }
 
-// MultiChannelChat manages communication across multiple frequency channels
+// DefaultChatSampleRate is the sample rate of a MultiChannelChat unless
+// changed with SetSampleRate. Every sound card supports it, but predefined
+// channels 2 and 3 need SetSampleRate(96000).
+const DefaultChatSampleRate = 48000
+
+// MultiChannelChat manages communication across multiple frequency channels.
+// All channels share one capture stream, split into per-channel bands by a
+// channelizer, and one playback stream mixing every channel's output.
 type MultiChannelChat struct {
 	channels    map[int]*ChatSession
 	activeChans []int
 	username    string
 	mu          sync.RWMutex
 	msgCallback func(channelID int, username, message string)
+	onMessage   func(channelID int, msg ChatMessage)
+	nextID      uint32
+	sent        func(channelID int, id uint64, err error)
+	lbt         bool // Listen before talk
+	csma        CSMAConfig
+	sampleRate  int
+	capture     *SharedSource // nil until the first join on the sound card
+	playback    *Mixer
 }
 
-// NewMultiChannelChat creates a new multi-channel chat system
+// NewMultiChannelChat creates a new multi-channel chat system on the
+// default capture and playback devices, opened when the first channel is
+// joined. msgCallback receives the sender and body of every message and may
+// be nil when OnMessage is used instead.
 func NewMultiChannelChat(username string, msgCallback func(int, string, string)) *MultiChannelChat {
 	return &MultiChannelChat{
 		channels:    make(map[int]*ChatSession),
 		username:    username,
 		msgCallback: msgCallback,
+		lbt:         true,
+		csma:        DefaultCSMAConfig(),
+		sampleRate:  DefaultChatSampleRate,
+		nextID:      rand.Uint32(),
+	}
+}
+
+// NewMultiChannelChatWithAudio creates a multi-channel chat system that
+// listens on source and transmits into sink at sampleRate.
+func NewMultiChannelChatWithAudio(username string, source AudioSource, sink AudioSink, sampleRate int, msgCallback func(int, string, string)) *MultiChannelChat {
+	mc := NewMultiChannelChat(username, msgCallback)
+	mc.sampleRate = sampleRate
+	mc.capture = NewSharedSource(source)
+	mc.playback = NewMixer(sink)
+	return mc
+}
+
+// SetSampleRate sets the sample rate of the shared audio streams. Channels
+// above 24 kHz need 96000. It fails once a channel has been joined.
+func (mc *MultiChannelChat) SetSampleRate(sampleRate int) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if len(mc.channels) > 0 {
+		return fmt.Errorf("cannot change the sample rate with %d channels joined", len(mc.channels))
+	}
+	mc.sampleRate = sampleRate
+	return nil
+}
+
+// openAudio creates the shared streams on the sound card. mc.mu must be
+// held.
+func (mc *MultiChannelChat) openAudio() error {
+	if mc.capture != nil {
+		return nil
+	}
+	source, err := NewMalgoSource()
+	if err != nil {
+		return err
+	}
+	sink, err := NewMalgoSink()
+	if err != nil {
+		source.Close()
+		return err
+	}
+	mc.capture = NewSharedSource(source)
+	mc.playback = NewMixer(sink)
+	return nil
+}
+
+// OnMessage sets a callback receiving every chat message with its sender,
+// ID and timestamp, alongside the callback given to NewMultiChannelChat. A
+// broadcast arrives once per channel it was heard on with the same ID.
+func (mc *MultiChannelChat) OnMessage(callback func(channelID int, msg ChatMessage)) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.onMessage = callback
+}
+
+// deliver parses a received payload and hands it to the callbacks.
+// Payloads without a chat message, from a plain ChatSession or a peer that
+// sends "username: message" text, are delivered as the body of a message
+// with no sender. Damaged chat messages, which fail the length or CRC
+// check, are dropped.
+func (mc *MultiChannelChat) deliver(channelID int, payload string) {
+	msg, err := ParseChatMessage(payload)
+	switch {
+	case errors.Is(err, ErrNotChatMessage) && payload != "":
+		msg = ChatMessage{Time: time.Now(), Body: payload}
+	case err != nil:
+		return
+	}
+
+	mc.mu.RLock()
+	onMessage := mc.onMessage
+	mc.mu.RUnlock()
+
+	if onMessage != nil {
+		onMessage(channelID, msg)
+	}
+	if mc.msgCallback != nil {
+		mc.msgCallback(channelID, msg.Sender, msg.Body)
+	}
+}
+
+// newMessage stamps a body with the username, the next ID and the current
+// time.
+func (mc *MultiChannelChat) newMessage(body string) ChatMessage {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.nextID++
+	return ChatMessage{
+		Sender: mc.username,
+		ID:     mc.nextID,
+		Time:   time.Now(),
+		Body:   body,
+	}
+}
+
+// SetListenBeforeTalk enables or disables carrier sensing on every joined
+// channel and on channels joined later. It is enabled by default with
+// DefaultCSMAConfig, so a message waits for a quiet channel instead of
+// colliding with another station.
+func (mc *MultiChannelChat) SetListenBeforeTalk(enabled bool, config CSMAConfig) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.lbt = enabled
+	mc.csma = config
+	for _, chatSession := range mc.channels {
+		chatSession.SetListenBeforeTalk(enabled, config)
+	}
+}
+
+// OnSent sets a callback reporting the outcome of every message sent on
+// any channel: nil once it has played, ErrChannelBusy if the channel never
+// cleared, or why it was canceled or failed.
+func (mc *MultiChannelChat) OnSent(callback func(channelID int, id uint64, err error)) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.sent = callback
+	for channelID, chatSession := range mc.channels {
+		mc.forwardSent(channelID, chatSession)
 	}
 }
 
-// JoinChannel joins a specific frequency channel
+// forwardSent routes the send outcomes of a session to the OnSent
+// callback. mc.mu must be held.
+func (mc *MultiChannelChat) forwardSent(channelID int, chatSession *ChatSession) {
+	callback := mc.sent
+	if callback == nil {
+		chatSession.OnSent(nil)
+		return
+	}
+	chatSession.OnSent(func(id uint64, err error) {
+		callback(channelID, id, err)
+	})
+}
+
+// JoinChannel joins a specific frequency channel. The channel listens to
+// its band of the shared capture stream, from half a tone spacing below its
+// lowest tone to half a spacing above its highest.
 func (mc *MultiChannelChat) JoinChannel(channelConfig ChannelConfig, order int, baudRate float64) error {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 
-	config := core.Config{
-		BaseFreq:    channelConfig.BaseFreq,
-		FreqSpacing: channelConfig.FreqSpacing,
-		Order:       order,
-		BaudRate:    baudRate,
-		SampleRate:  48000,
+	if _, joined := mc.channels[channelConfig.ID]; joined {
+		return fmt.Errorf("already connected to channel %d", channelConfig.ID)
 	}
 
-	modem := core.New(config)
-	chatSession, err := NewChatSession(modem)
+	// Chat sessions need timing recovery to tell where a message ends, and
+	// continuous phase keeps each channel from splattering into its
+	// neighbours
+	config := core.UltrasonicConfig()
+	config.BaseFreq = channelConfig.BaseFreq
+	config.FreqSpacing = channelConfig.FreqSpacing
+	config.Order = order
+	config.BaudRate = baudRate
+	config.SampleRate = mc.sampleRate
+	config.TimingRecovery = true
+	config.Modulation = core.ModulationCPFSK
+
+	modem, err := core.NewWithError(config)
+	if errors.Is(err, core.ErrAliasing) {
+		return fmt.Errorf("channel %d does not fit in %d Hz audio, call SetSampleRate(96000) before joining: %w", channelConfig.ID, mc.sampleRate, err)
+	}
 	if err != nil {
+		return fmt.Errorf("invalid configuration for channel %d: %v", channelConfig.ID, err)
+	}
+
+	if err := mc.openAudio(); err != nil {
 		return fmt.Errorf("failed to create chat session for channel %d: %v", channelConfig.ID, err)
 	}
+	frequencies := modem.Frequencies()
+	low, high := frequencies[0], frequencies[0]
+	for _, freq := range frequencies {
+		low = math.Min(low, freq)
+		high = math.Max(high, freq)
+	}
+	guard := channelConfig.FreqSpacing / 2
+	source := mc.capture.Tap(low-guard, high+guard)
+
+	chatSession := NewChatSessionWithAudio(modem, source, mc.playback.Input())
+	chatSession.SetListenBeforeTalk(mc.lbt, mc.csma)
+	mc.forwardSent(channelConfig.ID, chatSession)
 
 	err = chatSession.Start()
 	if err != nil {
+		chatSession.Close()
 		return fmt.Errorf("failed to start chat session for channel %d: %v", channelConfig.ID, err)
 	}
 
 	// Set up message forwarding
 	go func() {
-		for msg := range chatSession.ReceiveMessages() {
-			if mc.msgCallback != nil {
-				mc.msgCallback(channelConfig.ID, "Remote", msg)
-			}
+		for payload := range chatSession.ReceiveMessages() {
+			mc.deliver(channelConfig.ID, payload)
 		}
 	}()
 
@@ -128,33 +324,45 @@ func (mc *MultiChannelChat) LeaveChannel(channelID int) error {
 	return nil
 }
 
-// SendMessage sends a message to a specific channel
+// SendMessage queues a message on a specific channel and returns at once.
+// The outcome is reported through OnSent.
 func (mc *MultiChannelChat) SendMessage(channelID int, message string) error {
+	_, err := mc.Send(channelID, message)
+	return err
+}
+
+// Send queues a message on a specific channel and returns its
+// Transmission, which completes once the message has played or failed.
+// With listen-before-talk it fails with ErrChannelBusy when the channel
+// stays occupied, and its Backoffs count how often it deferred.
+func (mc *MultiChannelChat) Send(channelID int, message string) (*Transmission, error) {
+	return mc.send(channelID, mc.newMessage(message))
+}
+
+// send queues an encoded chat message on a channel.
+func (mc *MultiChannelChat) send(channelID int, msg ChatMessage) (*Transmission, error) {
 	mc.mu.RLock()
 	chatSession, exists := mc.channels[channelID]
 	mc.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("not connected to channel %d", channelID)
+		return nil, fmt.Errorf("not connected to channel %d", channelID)
 	}
-
-	// Add username prefix
-	fullMessage := fmt.Sprintf("%s: %s", mc.username, message)
-	chatSession.SendMessage(fullMessage)
-
-	return nil
+	return chatSession.SendMessage(msg.Marshal()), nil
 }
 
-// BroadcastMessage sends a message to all active channels
+// BroadcastMessage sends a message to all active channels. Every copy
+// carries the same ID, so receivers can tell them apart from new messages.
 func (mc *MultiChannelChat) BroadcastMessage(message string) error {
 	mc.mu.RLock()
 	activeChannels := make([]int, len(mc.activeChans))
 	copy(activeChannels, mc.activeChans)
 	mc.mu.RUnlock()
 
+	msg := mc.newMessage(message)
 	var lastErr error
 	for _, channelID := range activeChannels {
-		if err := mc.SendMessage(channelID, message); err != nil {
+		if _, err := mc.send(channelID, msg); err != nil {
 			lastErr = err
 		}
 	}
@@ -172,7 +380,7 @@ func (mc *MultiChannelChat) GetActiveChannels() []int {
 	return channels
 }
 
-// Close closes all channel connections
+// Close closes all channel connections and the shared audio streams
 func (mc *MultiChannelChat) Close() {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
@@ -180,85 +388,13 @@ func (mc *MultiChannelChat) Close() {
 	for _, chatSession := range mc.channels {
 		chatSession.Close()
 	}
+	if mc.capture != nil {
+		mc.capture.Close()
+		mc.playback.Close()
+		mc.capture = nil
+		mc.playback = nil
+	}
 
 	mc.channels = make(map[int]*ChatSession)
 	mc.activeChans = nil
-}
-
-// ChannelAnalyzer analyzes frequency channel conditions
-type ChannelAnalyzer struct {
-	modem    *core.Modem
-	recorder *Receiver
-	mu       sync.RWMutex
-	activity map[float64]float64 // frequency -> activity level
-	running  bool
-}
-
-// NewChannelAnalyzer creates a new channel analyzer
-func NewChannelAnalyzer() *ChannelAnalyzer {
-	// Use wideband configuration for analysis
-	config := core.Config{
-		BaseFreq:    18000, // Wide range for analysis
-		FreqSpacing: 100,   // Fine resolution
-		Order:       2,
-		BaudRate:    100,
-		SampleRate:  48000,
-	}
-
-	modem := core.New(config)
-	return &ChannelAnalyzer{
-		modem:    modem,
-		activity: make(map[float64]float64),
-	}
-}
-
-// StartAnalysis begins monitoring channel activity
-func (ca *ChannelAnalyzer) StartAnalysis() error {
-	receiver, err := NewReceiver(ca.modem, func(data []byte) {
-		// Activity detected callback
-		ca.mu.Lock()
-		defer ca.mu.Unlock()
-
-		// Simple activity detection based on received data
-		if len(data) > 0 {
-			for freq := 18000.0; freq <= 28000.0; freq += 1000.0 {
-				ca.activity[freq] = float64(time.Now().Unix())
-			}
-		}
-	})
-
-	if err != nil {
-		return err
-	}
-
-	ca.recorder = receiver
-	ca.running = true
-	return receiver.Start()
-}
-
-// GetChannelActivity returns current activity levels for frequency ranges
-func (ca *ChannelAnalyzer) GetChannelActivity() map[float64]float64 {
-	ca.mu.RLock()
-	defer ca.mu.RUnlock()
-
-	activity := make(map[float64]float64)
-	now := float64(time.Now().Unix())
-
-	for freq, lastActivity := range ca.activity {
-		// Activity fades over time
-		age := now - lastActivity
-		if age < 10.0 { // Active within last 10 seconds
-			activity[freq] = 1.0 - (age / 10.0)
-		}
-	}
-
-	return activity
-}
-
-// Stop stops the channel analysis
-func (ca *ChannelAnalyzer) Stop() {
-	ca.running = false
-	if ca.recorder != nil {
-		ca.recorder.Close()
-	}
 }
\ No newline at end of file
@@ -0,0 +1,151 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// receive waits for the next message on messages.
+func receive(t *testing.T, messages <-chan ChatMessage) ChatMessage {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(10 * time.Second):
+		t.Fatal("no message received")
+		return ChatMessage{}
+	}
+}
+
+func TestMultiChannelChatLoopback(t *testing.T) {
+	loopback := NewLoopback()
+	channels := PredefinedChannels()
+	four, five := channels[3], channels[4]
+
+	alice := NewMultiChannelChatWithAudio("alice", loopback.Source(), loopback.Sink(), DefaultChatSampleRate, nil)
+	defer alice.Close()
+	bob := NewMultiChannelChatWithAudio("bob", loopback.Source(), loopback.Sink(), DefaultChatSampleRate, nil)
+	defer bob.Close()
+
+	received := map[int]chan ChatMessage{
+		four.ID: make(chan ChatMessage, 10),
+		five.ID: make(chan ChatMessage, 10),
+	}
+	bob.OnMessage(func(channelID int, msg ChatMessage) {
+		received[channelID] <- msg
+	})
+
+	for _, mc := range []*MultiChannelChat{alice, bob} {
+		mc.SetListenBeforeTalk(false, DefaultCSMAConfig())
+		for _, channel := range []ChannelConfig{four, five} {
+			if err := mc.JoinChannel(channel, 2, 100); err != nil {
+				t.Fatalf("JoinChannel(%d): %v", channel.ID, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		channel int
+		body    string
+	}{
+		{four.ID, "hello on four"},
+		{five.ID, "hello on five"},
+		{four.ID, "again on four"},
+	}
+	for _, tt := range tests {
+		transmission, err := alice.Send(tt.channel, tt.body)
+		if err != nil {
+			t.Fatalf("Send(%d, %q): %v", tt.channel, tt.body, err)
+		}
+		if err := transmission.Wait(context.Background()); err != nil {
+			t.Fatalf("Send(%d, %q) completed with %v", tt.channel, tt.body, err)
+		}
+
+		msg := receive(t, received[tt.channel])
+		if msg.Sender != "alice" || msg.Body != tt.body {
+			t.Errorf("channel %d: received %q from %q, want %q from alice", tt.channel, msg.Body, msg.Sender, tt.body)
+		}
+	}
+
+	for channelID, messages := range received {
+		select {
+		case msg := <-messages:
+			t.Errorf("channel %d: unexpected message %q from %q", channelID, msg.Body, msg.Sender)
+		default:
+		}
+	}
+}
+
+func TestPredefinedChannelsJoin(t *testing.T) {
+	tests := []struct {
+		sampleRate int
+		joinable   map[int]bool
+	}{
+		{96000, map[int]bool{1: true, 2: true, 3: true, 4: true, 5: true}},
+		{DefaultChatSampleRate, map[int]bool{1: true, 4: true, 5: true}},
+	}
+
+	for _, tt := range tests {
+		loopback := NewLoopback()
+		chat := NewMultiChannelChatWithAudio("alice", loopback.Source(), loopback.Sink(), tt.sampleRate, nil)
+		for _, channel := range PredefinedChannels() {
+			err := chat.JoinChannel(channel, 2, 100)
+			if joined := err == nil; joined != tt.joinable[channel.ID] {
+				t.Errorf("%d Hz: JoinChannel(%d) = %v, want joinable %v", tt.sampleRate, channel.ID, err, tt.joinable[channel.ID])
+			}
+		}
+		chat.Close()
+	}
+	// The sound card is only opened once the channel fits
+	chat := NewMultiChannelChat("alice", nil)
+	if err := chat.JoinChannel(PredefinedChannels()[1], 2, 100); !errors.Is(err, core.ErrAliasing) {
+		t.Errorf("JoinChannel(2) at the default rate = %v, want core.ErrAliasing", err)
+	}
+}
+
+func TestMultiChannelChatDeliver(t *testing.T) {
+	structured := ChatMessage{"alice", 7, time.Unix(1700000000, 0), "hello"}.Marshal()
+	tests := []struct {
+		name      string
+		payload   string
+		delivered bool
+		sender    string
+		body      string
+	}{
+		{"chat message", structured, true, "alice", "hello"},
+		{"plain text", "hello from a ChatSession", true, "", "hello from a ChatSession"},
+		{"old format", "bob: hi there", true, "", "bob: hi there"},
+		{"corrupt chat message", structured[:len(structured)-1], false, "", ""},
+		{"empty", "", false, "", ""},
+	}
+
+	loopback := NewLoopback()
+	chat := NewMultiChannelChatWithAudio("carol", loopback.Source(), loopback.Sink(), DefaultChatSampleRate, nil)
+	defer chat.Close()
+	var received []ChatMessage
+	chat.OnMessage(func(channelID int, msg ChatMessage) {
+		received = append(received, msg)
+	})
+
+	for _, tt := range tests {
+		received = nil
+		chat.deliver(4, tt.payload)
+		if !tt.delivered {
+			if len(received) != 0 {
+				t.Errorf("%s: delivered %+v, want nothing", tt.name, received)
+			}
+			continue
+		}
+		if len(received) != 1 {
+			t.Errorf("%s: %d messages delivered, want 1", tt.name, len(received))
+			continue
+		}
+		if msg := received[0]; msg.Sender != tt.sender || msg.Body != tt.body {
+			t.Errorf("%s: delivered %q from %q, want %q from %q", tt.name, msg.Body, msg.Sender, tt.body, tt.sender)
+		}
+	}
+}
\ No newline at end of file
@@ -1,153 +1,262 @@
 package realtime
 
 import (
-	"encoding/binary"
-	"fmt"
+	"context"
 	"sync"
+	"time"
 
-	"github.com/gen2brain/malgo"
 	"github.com/gleicon/go-fsk/fsk/core"
 )
 
+// DefaultMuteTail is how long a half-duplex session keeps its receiver
+// muted after a transmission, covering playback latency and room echo.
+const DefaultMuteTail = 300 * time.Millisecond
+
+// DefaultEchoWindow is how long after sending a message a received copy of
+// it is treated as the session's own echo.
+const DefaultEchoWindow = 5 * time.Second
+
 // ChatSession represents a duplex communication session.
 type ChatSession struct {
-	modem           *core.Modem
-	ctx             *malgo.AllocatedContext
-	captureDevice   *malgo.Device
-	playbackDevice  *malgo.Device
-	capturedSamples []float32
-	playbackSignal  []float32
-	playbackIndex   uint32
-	mu              sync.Mutex
-	messageQueue    chan string
-	running         bool
-}
-
-// NewChatSession creates a new duplex chat session.
+	modem        *core.Modem
+	source       AudioSource
+	sink         AudioSink
+	queue        *TransmitQueue
+	decoder      *core.StreamDecoder
+	carrier      *core.CarrierDetector // Senses other stations for listen-before-talk
+	pending      []byte                // Bytes of the transmission being received
+	mu           sync.Mutex
+	messageQueue chan string
+	running      bool
+	halfDuplex   bool
+	muteTail     time.Duration
+	keyed        bool      // A message is playing
+	muted        bool      // The receiver is discarding audio
+	mutedUntil   time.Time // End of the tail after the last transmission
+	echoWindow   time.Duration
+	sent         []echo // Recently sent payloads
+	echoes       uint64
+}
+
+// echo is a sent payload that may come back through the microphone.
+type echo struct {
+	id      uint64
+	payload string
+	expires time.Time // Zero while the message is playing
+}
+
+// NewChatSession creates a new duplex chat session on the default capture
+// and playback devices.
 func NewChatSession(modem *core.Modem) (*ChatSession, error) {
-	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
-		// Audio system messages (optional logging)
-	})
+	source, err := NewMalgoSource()
+	if err != nil {
+		return nil, err
+	}
+	sink, err := NewMalgoSink()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize audio context: %v", err)
+		source.Close()
+		return nil, err
 	}
+	return NewChatSessionWithAudio(modem, source, sink), nil
+}
 
-	return &ChatSession{
+// NewChatSessionWithAudio creates a chat session that listens on source and
+// transmits into sink.
+func NewChatSessionWithAudio(modem *core.Modem, source AudioSource, sink AudioSink) *ChatSession {
+	c := &ChatSession{
 		modem:        modem,
-		ctx:          ctx,
+		source:       source,
+		sink:         sink,
+		queue:        NewTransmitQueue(modem, sink),
+		decoder:      core.NewStreamDecoder(modem),
+		carrier:      core.NewCarrierDetector(modem, core.DefaultCarrierConfig()),
 		messageQueue: make(chan string, 10),
-	}, nil
+		muteTail:     DefaultMuteTail,
+		echoWindow:   DefaultEchoWindow,
+	}
+	c.queue.OnTransmit(c.onTransmit)
+	return c
 }
 
-// Start begins the chat session.
-func (c *ChatSession) Start() error {
-	// Configure capture device
-	captureConfig := malgo.DefaultDeviceConfig(malgo.Capture)
-	captureConfig.Capture.Format = malgo.FormatS16
-	captureConfig.Capture.Channels = 1
-	captureConfig.SampleRate = uint32(c.modem.Config().SampleRate)
-	captureConfig.Alsa.NoMMap = 1
-
-	// Configure playback device
-	playbackConfig := malgo.DefaultDeviceConfig(malgo.Playback)
-	playbackConfig.Playback.Format = malgo.FormatS16
-	playbackConfig.Playback.Channels = 1
-	playbackConfig.SampleRate = uint32(c.modem.Config().SampleRate)
-	playbackConfig.Alsa.NoMMap = 1
-
-	// Capture callback
-	onRecvFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
-		c.mu.Lock()
-		defer c.mu.Unlock()
-
-		for i := 0; i < len(pInputSamples); i += 2 {
-			sample := int16(binary.LittleEndian.Uint16(pInputSamples[i : i+2]))
-			floatSample := float32(sample) / 32767.0
-			c.capturedSamples = append(c.capturedSamples, floatSample)
-		}
+// SetHalfDuplex enables or disables half-duplex operation. While enabled the
+// receiver is muted from the start of each transmission until tail after
+// its end, so the session never decodes its own signal.
+func (c *ChatSession) SetHalfDuplex(enabled bool, tail time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		if len(c.capturedSamples) >= c.modem.SymbolPeriod()*4 {
-			decoded := c.modem.Decode(c.capturedSamples)
-			if len(decoded) > 0 {
-				decodedStr := string(decoded)
-				if decodedStr != "" {
-					select {
-					case c.messageQueue <- decodedStr:
-					default:
-					}
-				}
-			}
-			c.capturedSamples = c.capturedSamples[:0]
-		}
+	c.halfDuplex = enabled
+	c.muteTail = tail
+}
+
+// SetEchoFilter sets how long a sent message is remembered. A received
+// message identical to one still remembered is dropped as an echo, once per
+// send. A window of zero disables the filter.
+func (c *ChatSession) SetEchoFilter(window time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.echoWindow = window
+	if window <= 0 {
+		c.sent = nil
 	}
+}
+
+// SetListenBeforeTalk enables or disables carrier sensing before each
+// message. While enabled a message waits, backing off as config describes,
+// until no carrier is heard on the channel, and fails with ErrChannelBusy
+// if the channel stays busy.
+func (c *ChatSession) SetListenBeforeTalk(enabled bool, config CSMAConfig) {
+	if enabled {
+		c.queue.SetCarrierSense(c.ChannelBusy, config)
+	} else {
+		c.queue.SetCarrierSense(nil, config)
+	}
+}
+
+// ChannelBusy reports whether a carrier is currently heard on the channel.
+func (c *ChatSession) ChannelBusy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.carrier.Present()
+}
+
+// EchoesDropped returns how many received messages were dropped as echoes.
+func (c *ChatSession) EchoesDropped() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.echoes
+}
+
+// onTransmit tracks the transmit queue for muting and the echo filter.
+func (c *ChatSession) onTransmit(t *Transmission, active bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Playback callback
-	onSendFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
-		c.mu.Lock()
-		defer c.mu.Unlock()
-
-		for i := uint32(0); i < framecount; i++ {
-			var sample int16
-			if c.playbackIndex < uint32(len(c.playbackSignal)) {
-				floatSample := c.playbackSignal[c.playbackIndex]
-				if floatSample > 1.0 {
-					floatSample = 1.0
-				}
-				if floatSample < -1.0 {
-					floatSample = -1.0
-				}
-				sample = int16(floatSample * 32767)
-				c.playbackIndex++
-			} else {
-				sample = 0
-			}
-
-			outputIndex := i * 2
-			if outputIndex+1 < uint32(len(pOutputSample)) {
-				binary.LittleEndian.PutUint16(pOutputSample[outputIndex:], uint16(sample))
-			}
+	c.keyed = active
+	if active {
+		if c.echoWindow > 0 {
+			c.forgetExpired(time.Now())
+			c.sent = append(c.sent, echo{id: t.ID, payload: string(t.Data)})
 		}
+		return
 	}
 
-	// Initialize devices
-	captureDevice, err := malgo.InitDevice(c.ctx.Context, captureConfig, malgo.DeviceCallbacks{
-		Data: onRecvFrames,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to initialize capture device: %v", err)
+	now := time.Now()
+	c.mutedUntil = now.Add(c.muteTail)
+	for i := range c.sent {
+		if c.sent[i].id == t.ID {
+			c.sent[i].expires = now.Add(c.echoWindow)
+		}
 	}
-	c.captureDevice = captureDevice
+}
 
-	playbackDevice, err := malgo.InitDevice(c.ctx.Context, playbackConfig, malgo.DeviceCallbacks{
-		Data: onSendFrames,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to initialize playback device: %v", err)
+// forgetExpired drops sends older than the echo window. c.mu must be held.
+func (c *ChatSession) forgetExpired(now time.Time) {
+	kept := c.sent[:0]
+	for _, e := range c.sent {
+		if e.expires.IsZero() || !now.After(e.expires) {
+			kept = append(kept, e)
+		}
 	}
-	c.playbackDevice = playbackDevice
+	c.sent = kept
+}
 
-	// Start devices
-	err = c.captureDevice.Start()
-	if err != nil {
-		return fmt.Errorf("failed to start capture device: %v", err)
+// isEcho reports whether message matches a remembered send and forgets the
+// match. c.mu must be held.
+func (c *ChatSession) isEcho(message string) bool {
+	c.forgetExpired(time.Now())
+	for i, e := range c.sent {
+		if e.payload == message {
+			c.sent = append(c.sent[:i], c.sent[i+1:]...)
+			return true
+		}
 	}
+	return false
+}
 
-	err = c.playbackDevice.Start()
-	if err != nil {
-		return fmt.Errorf("failed to start playback device: %v", err)
+// Start begins the chat session.
+func (c *ChatSession) Start() error {
+	sampleRate := c.modem.Config().SampleRate
+
+	if err := c.queue.Start(); err != nil {
+		return err
+	}
+	if err := c.source.Start(sampleRate, c.onSamples); err != nil {
+		c.queue.Stop()
+		return err
 	}
 
+	c.mu.Lock()
 	c.running = true
+	c.mu.Unlock()
 	return nil
 }
 
-// SendMessage sends a text message.
-func (c *ChatSession) SendMessage(message string) {
+func (c *ChatSession) onSamples(samples []float32) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.playbackSignal = c.modem.Encode([]byte(message))
-	c.playbackIndex = 0
+	// In half-duplex mode our own transmission is never decoded
+	if c.halfDuplex && (c.keyed || time.Now().Before(c.mutedUntil)) {
+		if !c.muted {
+			c.muted = true
+			c.decoder.Reset()
+			c.carrier.Reset()
+			c.pending = c.pending[:0]
+		}
+		return
+	}
+	c.muted = false
+	c.carrier.Process(samples, nil)
+
+	// Collect bytes until the transmission ends, then deliver the message
+	c.decoder.WriteSamples(samples)
+	if n := c.decoder.Buffered(); n > 0 {
+		decoded := make([]byte, n)
+		c.decoder.Read(decoded)
+		c.pending = append(c.pending, decoded...)
+	}
+	if !c.decoder.Locked() && len(c.pending) > 0 {
+		message := string(c.pending)
+		c.pending = c.pending[:0]
+		if c.isEcho(message) {
+			c.echoes++
+			return
+		}
+		select {
+		case c.messageQueue <- message:
+		default:
+		}
+	}
+}
+
+// SendMessage queues a text message behind any message still being played
+// and returns at once.
+func (c *ChatSession) SendMessage(message string) *Transmission {
+	return c.queue.Send([]byte(message))
+}
+
+// SendAndWait queues a text message and blocks until it has played or ctx
+// ends.
+func (c *ChatSession) SendAndWait(ctx context.Context, message string) error {
+	return c.queue.SendAndWait(ctx, []byte(message))
+}
+
+// CancelMessage removes a queued message that has not started playing.
+func (c *ChatSession) CancelMessage(id uint64) bool {
+	return c.queue.Cancel(id)
+}
+
+// SetFrameGap sets the silence left between messages.
+func (c *ChatSession) SetFrameGap(gap time.Duration) {
+	c.queue.SetFrameGap(gap)
+}
+
+// OnSent sets a callback run whenever a queued message has played, been
+// canceled or failed.
+func (c *ChatSession) OnSent(callback func(id uint64, err error)) {
+	c.queue.OnComplete(callback)
 }
 
 // ReceiveMessages returns a channel for incoming messages.
@@ -168,22 +277,14 @@ func (c *ChatSession) Stop() {
 	c.running = false
 	c.mu.Unlock()
 
-	if c.captureDevice != nil {
-		c.captureDevice.Stop()
-		c.captureDevice.Uninit()
-	}
-	if c.playbackDevice != nil {
-		c.playbackDevice.Stop()
-		c.playbackDevice.Uninit()
-	}
+	c.source.Stop()
+	c.queue.Stop()
 }
 
 // Close cleans up resources.
 func (c *ChatSession) Close() {
 	c.Stop()
-	if c.ctx != nil {
-		c.ctx.Uninit()
-		c.ctx.Free()
-	}
+	c.source.Close()
+	c.sink.Close()
 	close(c.messageQueue)
 }
\ No newline at end of file
@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+func TestChatSessionLoopback(t *testing.T) {
+	tests := []struct {
+		name       string
+		halfDuplex bool
+		echoWindow time.Duration
+		hearsSelf  bool   // The sender decodes its own message
+		echoes     uint64 // Messages the sender drops as echoes
+	}{
+		{"full duplex", false, 0, true, 0},
+		{"echo filter", false, DefaultEchoWindow, false, 1},
+		{"half duplex", true, DefaultEchoWindow, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loopback := NewLoopback()
+			config := core.UltrasonicConfig()
+			config.TimingRecovery = true
+			modem := core.New(config)
+			alice := NewChatSessionWithAudio(modem, loopback.Source(), loopback.Sink())
+			defer alice.Close()
+			bob := NewChatSessionWithAudio(modem, loopback.Source(), loopback.Sink())
+			defer bob.Close()
+
+			alice.SetHalfDuplex(tt.halfDuplex, DefaultMuteTail)
+			alice.SetEchoFilter(tt.echoWindow)
+			for _, session := range []*ChatSession{alice, bob} {
+				if err := session.Start(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !alice.IsRunning() {
+				t.Error("IsRunning() = false after Start")
+			}
+
+			message := "hello bob"
+			if err := alice.SendAndWait(context.Background(), message); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case got := <-bob.ReceiveMessages():
+				if got != message {
+					t.Errorf("bob received %q, want %q", got, message)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("bob received nothing")
+			}
+
+			// Any echo reaches alice by the time bob has decoded the message
+			select {
+			case got := <-alice.ReceiveMessages():
+				if !tt.hearsSelf || got != message {
+					t.Errorf("alice received %q", got)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.hearsSelf {
+					t.Error("alice did not hear the echo of the message")
+				}
+			}
+			if echoes := alice.EchoesDropped(); echoes != tt.echoes {
+				t.Errorf("EchoesDropped() = %d, want %d", echoes, tt.echoes)
+			}
+		})
+	}
+}
\ No newline at end of file
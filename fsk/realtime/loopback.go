@@ -0,0 +1,234 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// loopbackTail is the silence a loopback sink delivers when it goes idle.
+const loopbackTail = 250 * time.Millisecond
+
+// Loopback is an in-memory audio medium. Everything written to any of its
+// sinks is delivered to every started source, as if all speakers and
+// microphones shared one quiet room. It lets receivers, transmitters and
+// chat sessions run without a sound card, for example in tests or CI.
+//
+// A sound card keeps delivering silence between transmissions, which is
+// how receivers notice that a carrier has dropped. The loopback has no
+// clock, so a sink follows its audio with loopbackTail of silence instead
+// when it is drained or stopped.
+type Loopback struct {
+	mu        sync.Mutex
+	sources   map[*LoopbackSource]bool // Started sources
+	pending   int                      // Blocks written but not yet delivered
+	delivered *sync.Cond
+}
+
+// NewLoopback creates an empty loopback medium.
+func NewLoopback() *Loopback {
+	l := &Loopback{sources: make(map[*LoopbackSource]bool)}
+	l.delivered = sync.NewCond(&l.mu)
+	return l
+}
+
+// Source returns a new source that hears every sink of the loopback.
+func (l *Loopback) Source() *LoopbackSource {
+	s := &LoopbackSource{loopback: l}
+	s.ready = sync.NewCond(&s.mu)
+	return s
+}
+
+// Sink returns a new sink that plays into the loopback.
+func (l *Loopback) Sink() *LoopbackSink {
+	return &LoopbackSink{loopback: l}
+}
+
+// broadcast splits samples into blocks and queues them on every source.
+func (l *Loopback) broadcast(samples []float32) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for start := 0; start < len(samples); start += sourceBlockSize {
+		end := start + sourceBlockSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		for source := range l.sources {
+			block := append([]float32(nil), samples[start:end]...)
+			source.enqueue(block)
+			l.pending++
+		}
+	}
+}
+
+// done records that a source delivered or dropped count blocks.
+func (l *Loopback) done(count int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pending -= count
+	if l.pending == 0 {
+		l.delivered.Broadcast()
+	}
+}
+
+// LoopbackSource receives the audio written to the sinks of a Loopback.
+// Blocks are delivered in order from a goroutine of their own.
+type LoopbackSource struct {
+	loopback *Loopback
+	mu       sync.Mutex
+	ready    *sync.Cond
+	queue    [][]float32
+	running  bool
+	exited   chan struct{}
+}
+
+// Start registers the source with the loopback and starts delivering blocks.
+func (s *LoopbackSource) Start(sampleRate int, onSamples func(samples []float32)) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = true
+	s.exited = make(chan struct{})
+	s.mu.Unlock()
+
+	s.loopback.mu.Lock()
+	s.loopback.sources[s] = true
+	s.loopback.mu.Unlock()
+
+	go s.deliver(onSamples)
+	return nil
+}
+
+func (s *LoopbackSource) deliver(onSamples func(samples []float32)) {
+	defer close(s.exited)
+
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && s.running {
+			s.ready.Wait()
+		}
+		if !s.running {
+			s.mu.Unlock()
+			return
+		}
+		block := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		onSamples(block)
+		s.loopback.done(1)
+	}
+}
+
+// enqueue is called by the loopback with its lock held.
+func (s *LoopbackSource) enqueue(block []float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = append(s.queue, block)
+	s.ready.Signal()
+}
+
+// Stop unregisters the source and discards blocks not yet delivered.
+func (s *LoopbackSource) Stop() error {
+	s.loopback.mu.Lock()
+	delete(s.loopback.sources, s)
+	s.loopback.mu.Unlock()
+
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	dropped := len(s.queue)
+	s.queue = nil
+	s.ready.Signal()
+	exited := s.exited
+	s.mu.Unlock()
+
+	<-exited
+	s.loopback.done(dropped)
+	return nil
+}
+
+// Unpaced marks the source as delivering faster than real time.
+func (s *LoopbackSource) Unpaced() {}
+
+// Close stops the source.
+func (s *LoopbackSource) Close() error {
+	return s.Stop()
+}
+
+// LoopbackSink plays into a Loopback.
+type LoopbackSink struct {
+	loopback *Loopback
+	mu       sync.Mutex
+	tail     []float32 // Silence following the audio once the sink goes idle
+	written  bool      // Samples were written since the last tail
+}
+
+// Start sizes the silence that follows the audio for sampleRate.
+func (s *LoopbackSink) Start(sampleRate int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tail = make([]float32, int(float64(sampleRate)*loopbackTail.Seconds()))
+	return nil
+}
+
+// Write delivers samples to every started source of the loopback.
+// Consecutive writes join without a gap, like a sound card's stream.
+func (s *LoopbackSink) Write(samples []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loopback.broadcast(samples)
+	if len(samples) > 0 {
+		s.written = true
+	}
+	return nil
+}
+
+// idle delivers the silence that follows the audio written since the sink
+// last went idle.
+func (s *LoopbackSink) idle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.written {
+		s.loopback.broadcast(s.tail)
+		s.written = false
+	}
+}
+
+// Drain delivers a short silence after the audio written so far, then
+// waits until every started source has received everything written to the
+// loopback.
+func (s *LoopbackSink) Drain() error {
+	s.idle()
+
+	l := s.loopback
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.pending > 0 {
+		l.delivered.Wait()
+	}
+	return nil
+}
+
+// Stop delivers a short silence after the audio written so far; written
+// samples are already on their way.
+func (s *LoopbackSink) Stop() error {
+	s.idle()
+	return nil
+}
+
+// Close stops the sink.
+func (s *LoopbackSink) Close() error {
+	return s.Stop()
+}
\ No newline at end of file
@@ -0,0 +1,217 @@
+package realtime
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+// playbackLatency is how long Drain waits after the sound card has taken
+// the last queued sample, for it to leave the device buffer.
+const playbackLatency = 200 * time.Millisecond
+
+func newMalgoContext() (*malgo.AllocatedContext, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
+		// Audio system messages (optional logging)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize audio context: %v", err)
+	}
+	return ctx, nil
+}
+
+// MalgoSource captures 16-bit mono audio from the default input device.
+type MalgoSource struct {
+	ctx     *malgo.AllocatedContext
+	device  *malgo.Device
+	samples []float32 // Captured frames converted to float32
+}
+
+// NewMalgoSource creates a source on the default capture device.
+func NewMalgoSource() (*MalgoSource, error) {
+	ctx, err := newMalgoContext()
+	if err != nil {
+		return nil, err
+	}
+	return &MalgoSource{ctx: ctx}, nil
+}
+
+// Start begins capture.
+func (s *MalgoSource) Start(sampleRate int, onSamples func(samples []float32)) error {
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
+	deviceConfig.Capture.Format = malgo.FormatS16
+	deviceConfig.Capture.Channels = 1
+	deviceConfig.SampleRate = uint32(sampleRate)
+	deviceConfig.Alsa.NoMMap = 1
+
+	onRecvFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
+		s.samples = pcm16ToFloat(s.samples[:0], pInputSamples)
+		onSamples(s.samples)
+	}
+
+	device, err := malgo.InitDevice(s.ctx.Context, deviceConfig, malgo.DeviceCallbacks{
+		Data: onRecvFrames,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize capture device: %v", err)
+	}
+
+	if err := device.Start(); err != nil {
+		device.Uninit()
+		return fmt.Errorf("failed to start capture device: %v", err)
+	}
+
+	s.device = device
+	return nil
+}
+
+// Stop stops capture.
+func (s *MalgoSource) Stop() error {
+	if s.device != nil {
+		s.device.Stop()
+		s.device.Uninit()
+		s.device = nil
+	}
+	return nil
+}
+
+// Close stops capture and releases the audio context.
+func (s *MalgoSource) Close() error {
+	s.Stop()
+	if s.ctx != nil {
+		s.ctx.Uninit()
+		s.ctx.Free()
+		s.ctx = nil
+	}
+	return nil
+}
+
+// MalgoSink plays 16-bit mono audio on the default output device. Queued
+// samples are played back to back; silence fills the gaps.
+type MalgoSink struct {
+	ctx     *malgo.AllocatedContext
+	device  *malgo.Device
+	mu      sync.Mutex
+	queue   []float32 // Samples waiting for the device
+	block   []float32 // Scratch buffer for the device callback
+	drained *sync.Cond
+}
+
+// NewMalgoSink creates a sink on the default playback device.
+func NewMalgoSink() (*MalgoSink, error) {
+	ctx, err := newMalgoContext()
+	if err != nil {
+		return nil, err
+	}
+	s := &MalgoSink{ctx: ctx}
+	s.drained = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+// Start opens the playback device.
+func (s *MalgoSink) Start(sampleRate int) error {
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Playback)
+	deviceConfig.Playback.Format = malgo.FormatS16
+	deviceConfig.Playback.Channels = 1
+	deviceConfig.SampleRate = uint32(sampleRate)
+	deviceConfig.Alsa.NoMMap = 1
+
+	onSendFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		frames := int(framecount)
+		if frames > len(pOutputSample)/2 {
+			frames = len(pOutputSample) / 2
+		}
+		if cap(s.block) < frames {
+			s.block = make([]float32, frames)
+		}
+		block := s.block[:frames]
+
+		n := copy(block, s.queue)
+		for i := n; i < frames; i++ {
+			block[i] = 0
+		}
+		s.queue = s.queue[n:]
+		if len(s.queue) == 0 {
+			s.queue = nil
+			s.drained.Broadcast()
+		}
+
+		floatToPCM16(pOutputSample, block)
+	}
+
+	device, err := malgo.InitDevice(s.ctx.Context, deviceConfig, malgo.DeviceCallbacks{
+		Data: onSendFrames,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize playback device: %v", err)
+	}
+
+	if err := device.Start(); err != nil {
+		device.Uninit()
+		return fmt.Errorf("failed to start playback device: %v", err)
+	}
+
+	s.mu.Lock()
+	s.device = device
+	s.mu.Unlock()
+	return nil
+}
+
+// Write queues samples for playback.
+func (s *MalgoSink) Write(samples []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = append(s.queue, samples...)
+	return nil
+}
+
+// Drain waits until the device has played every queued sample.
+func (s *MalgoSink) Drain() error {
+	s.mu.Lock()
+	for len(s.queue) > 0 && s.device != nil {
+		s.drained.Wait()
+	}
+	playing := s.device != nil
+	s.mu.Unlock()
+
+	if playing {
+		time.Sleep(playbackLatency)
+	}
+	return nil
+}
+
+// Stop closes the playback device and discards queued samples.
+func (s *MalgoSink) Stop() error {
+	s.mu.Lock()
+	device := s.device
+	s.device = nil
+	s.mu.Unlock()
+
+	if device != nil {
+		device.Stop()
+		device.Uninit()
+	}
+
+	s.mu.Lock()
+	s.queue = nil
+	s.drained.Broadcast()
+	s.mu.Unlock()
+	return nil
+}
+
+// Close stops playback and releases the audio context.
+func (s *MalgoSink) Close() error {
+	s.Stop()
+	if s.ctx != nil {
+		s.ctx.Uninit()
+		s.ctx.Free()
+		s.ctx = nil
+	}
+	return nil
+}
\ No newline at end of file
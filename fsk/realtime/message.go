@@ -0,0 +1,123 @@
+package realtime
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chat messages travel as a record separator, the length of the fields as
+// a uvarint, the fields and a big-endian CRC-32 (IEEE) covering the length
+// and the fields. The fields are the sender, ID, Unix time and body
+// separated by unit separators; IDs and times are hex to keep the header
+// short on a slow link. The length lets the receiver ignore noise decoded
+// after the message, and the CRC rejects damaged messages.
+const (
+	messageStart     = "\x1e"
+	messageSeparator = "\x1f"
+	messageCRCSize   = 4
+)
+
+// Errors returned by ParseChatMessage, for use with errors.Is.
+var (
+	ErrNotChatMessage     = errors.New("not a chat message")
+	ErrCorruptChatMessage = errors.New("corrupt chat message")
+)
+
+// ChatMessage is a message exchanged by MultiChannelChat.
+type ChatMessage struct {
+	Sender string    // Username of the sending station
+	ID     uint32    // Identifier, unique per sender
+	Time   time.Time // When it was sent, to the second
+	Body   string
+}
+
+// Marshal encodes the message for transmission. Separator characters in
+// the sender are dropped, the body is sent as is.
+func (m ChatMessage) Marshal() string {
+	fields := m.fields()
+	frame := append([]byte(messageStart), binary.AppendUvarint(nil, uint64(len(fields)))...)
+	frame = append(frame, fields...)
+	frame = binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(frame[len(messageStart):]))
+	return string(frame)
+}
+
+// fields returns the separated fields of the message.
+func (m ChatMessage) fields() string {
+	sender := strings.Map(func(r rune) rune {
+		if r == '\x1e' || r == '\x1f' {
+			return -1
+		}
+		return r
+	}, m.Sender)
+
+	var b strings.Builder
+	b.WriteString(sender)
+	b.WriteString(messageSeparator)
+	b.WriteString(strconv.FormatUint(uint64(m.ID), 16))
+	b.WriteString(messageSeparator)
+	b.WriteString(strconv.FormatInt(m.Time.Unix(), 16))
+	b.WriteString(messageSeparator)
+	b.WriteString(m.Body)
+	return b.String()
+}
+
+// ParseChatMessage decodes a payload produced by Marshal. Bytes before the
+// record separator and after the CRC, such as noise decoded around the
+// message, are ignored. It returns ErrNotChatMessage if the payload holds no
+// message start, and an error wrapping ErrCorruptChatMessage if the length,
+// the CRC or a field is wrong.
+func ParseChatMessage(payload string) (ChatMessage, error) {
+	// Noise before the message may hold a record separator of its own
+	err := ErrNotChatMessage
+	for offset := 0; ; {
+		start := strings.Index(payload[offset:], messageStart)
+		if start < 0 {
+			return ChatMessage{}, err
+		}
+		offset += start + len(messageStart)
+
+		var msg ChatMessage
+		if msg, err = parseFrame([]byte(payload[offset:])); err == nil {
+			return msg, nil
+		}
+	}
+}
+
+// parseFrame checks the length and CRC of a frame following the record
+// separator and decodes its fields.
+func parseFrame(frame []byte) (ChatMessage, error) {
+	length, n := binary.Uvarint(frame)
+	if n <= 0 || len(frame)-n < messageCRCSize || length > uint64(len(frame)-n-messageCRCSize) {
+		return ChatMessage{}, fmt.Errorf("%w: truncated", ErrCorruptChatMessage)
+	}
+	end := n + int(length)
+	if crc32.ChecksumIEEE(frame[:end]) != binary.BigEndian.Uint32(frame[end:]) {
+		return ChatMessage{}, fmt.Errorf("%w: CRC mismatch", ErrCorruptChatMessage)
+	}
+
+	fields := strings.SplitN(string(frame[n:end]), messageSeparator, 4)
+	if len(fields) != 4 {
+		return ChatMessage{}, fmt.Errorf("%w: %d of 4 fields", ErrCorruptChatMessage, len(fields))
+	}
+
+	id, err := strconv.ParseUint(fields[1], 16, 32)
+	if err != nil {
+		return ChatMessage{}, fmt.Errorf("%w: invalid ID %q", ErrCorruptChatMessage, fields[1])
+	}
+	seconds, err := strconv.ParseInt(fields[2], 16, 64)
+	if err != nil {
+		return ChatMessage{}, fmt.Errorf("%w: invalid time %q", ErrCorruptChatMessage, fields[2])
+	}
+
+	return ChatMessage{
+		Sender: fields[0],
+		ID:     uint32(id),
+		Time:   time.Unix(seconds, 0),
+		Body:   fields[3],
+	}, nil
+}
\ No newline at end of file
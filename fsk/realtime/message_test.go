@@ -0,0 +1,69 @@
+package realtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChatMessageRoundTrip(t *testing.T) {
+	sent := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		msg  ChatMessage
+		want ChatMessage
+	}{
+		{"plain", ChatMessage{"alice", 1, sent, "hello"}, ChatMessage{"alice", 1, sent, "hello"}},
+		{"empty body", ChatMessage{"bob", 0xFFFFFFFF, sent, ""}, ChatMessage{"bob", 0xFFFFFFFF, sent, ""}},
+		{"separators in body", ChatMessage{"carol", 7, sent, "a\x1fb\x1ec"}, ChatMessage{"carol", 7, sent, "a\x1fb\x1ec"}},
+		{"separators in sender", ChatMessage{"da\x1eve\x1f", 2, sent, "hi"}, ChatMessage{"dave", 2, sent, "hi"}},
+		{"long body", ChatMessage{"erin", 3, sent, string(make([]byte, 300))}, ChatMessage{"erin", 3, sent, string(make([]byte, 300))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := tt.msg.Marshal()
+			for _, noisy := range []string{payload, "#\x1e!" + payload, payload + "##", "\x02" + payload + "\x1e\x05"} {
+				got, err := ParseChatMessage(noisy)
+				if err != nil {
+					t.Fatalf("ParseChatMessage(%q): %v", noisy, err)
+				}
+				if got.Sender != tt.want.Sender || got.ID != tt.want.ID || !got.Time.Equal(tt.want.Time) || got.Body != tt.want.Body {
+					t.Errorf("ParseChatMessage(%q) = %+v, want %+v", noisy, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseChatMessageRejects(t *testing.T) {
+	payload := ChatMessage{"alice", 42, time.Unix(1700000000, 0), "hello on four"}.Marshal()
+
+	tests := []struct {
+		name    string
+		payload string
+		want    error
+	}{
+		{"empty", "", ErrNotChatMessage},
+		{"plain text", "hello on four", ErrNotChatMessage},
+		{"truncated", payload[:len(payload)-1], ErrCorruptChatMessage},
+		{"start only", "\x1e", ErrCorruptChatMessage},
+		{"huge length", "\x1e\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01", ErrCorruptChatMessage},
+	}
+	// Every single damaged byte after the start must fail the check
+	for i := 1; i < len(payload); i++ {
+		damaged := []byte(payload)
+		damaged[i] ^= 0x10
+		tests = append(tests, struct {
+			name    string
+			payload string
+			want    error
+		}{"damaged byte", string(damaged), ErrCorruptChatMessage})
+	}
+
+	for _, tt := range tests {
+		if msg, err := ParseChatMessage(tt.payload); !errors.Is(err, tt.want) {
+			t.Errorf("%s: ParseChatMessage(%q) = %+v, %v, want %v", tt.name, tt.payload, msg, err, tt.want)
+		}
+	}
+}
\ No newline at end of file
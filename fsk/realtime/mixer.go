@@ -0,0 +1,305 @@
+package realtime
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	mixBlock = 1024                   // Samples mixed at a time
+	mixLead  = 100 * time.Millisecond // Audio handed to the sink ahead of real time
+)
+
+// Mixer plays the output of many transmitters through one sink. Each
+// transmitter writes into a MixerInput, an AudioSink of its own; the mixer
+// sums the inputs block by block, in real time, so signals written at the
+// same moment on different channels play at the same moment. Where several
+// inputs overlap the sum is scaled down by their number, so it does not
+// clip.
+//
+// The sink is started with the first input and stopped with the last.
+type Mixer struct {
+	sink       AudioSink
+	state      sync.Mutex // Serializes starting and stopping inputs
+	mu         sync.Mutex
+	ready      *sync.Cond // Signals queued samples or stopping
+	mixed      *sync.Cond // Signals a block handed to the sink
+	inputs     map[*MixerInput]bool
+	sampleRate int
+	running    bool
+	stopping   bool
+	err        error // Last error from the sink
+	wake       chan struct{}
+	exited     chan struct{}
+	block      []float32
+}
+
+// NewMixer creates a mixer playing into sink.
+func NewMixer(sink AudioSink) *Mixer {
+	m := &Mixer{
+		sink:   sink,
+		inputs: make(map[*MixerInput]bool),
+		block:  make([]float32, mixBlock),
+	}
+	m.ready = sync.NewCond(&m.mu)
+	m.mixed = sync.NewCond(&m.mu)
+	return m
+}
+
+// Input returns a new input of the mixer.
+func (m *Mixer) Input() *MixerInput {
+	return &MixerInput{mixer: m}
+}
+
+// queued reports whether any input has samples waiting. m.mu must be held.
+func (m *Mixer) queued() bool {
+	for input := range m.inputs {
+		if len(input.queue) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// run mixes queued samples into the sink until the mixer stops. While any
+// input has samples the mixer keeps at most mixLead of audio ahead of the
+// wall clock; once they run out it waits, and the clock restarts with the
+// next write.
+func (m *Mixer) run(wake, exited chan struct{}) {
+	defer close(exited)
+
+	var start time.Time
+	played := 0
+	gain := float32(1)
+	for {
+		m.mu.Lock()
+		for !m.stopping && !m.queued() {
+			start = time.Time{}
+			m.ready.Wait()
+		}
+		if m.stopping {
+			m.mu.Unlock()
+			return
+		}
+		if start.IsZero() {
+			start = time.Now()
+			played = 0
+		}
+
+		n, sources := m.mix()
+		sampleRate := m.sampleRate
+		m.mu.Unlock()
+
+		// Ramp the gain across the block so a channel starting or stopping
+		// does not click on the others
+		target := 1 / float32(sources)
+		for i := range m.block[:n] {
+			m.block[i] *= gain + (target-gain)*float32(i+1)/float32(n)
+		}
+		gain = target
+		err := m.sink.Write(m.block[:n])
+		played += n
+		due := start.Add(time.Duration(played) * time.Second / time.Duration(sampleRate))
+
+		m.mu.Lock()
+		if err != nil {
+			m.err = err
+		}
+		for input := range m.inputs {
+			if input.writing {
+				input.writing = false
+				input.due = due
+			}
+		}
+		m.mixed.Broadcast()
+		m.mu.Unlock()
+
+		if wait := time.Until(due) - mixLead; wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-wake:
+			}
+		}
+	}
+}
+
+// mix sums the next block of every input into m.block, returning its
+// length and how many inputs contributed. m.mu must be held.
+func (m *Mixer) mix() (n, sources int) {
+	for i := range m.block {
+		m.block[i] = 0
+	}
+	for input := range m.inputs {
+		k := len(input.queue)
+		if k == 0 {
+			continue
+		}
+		if k > mixBlock {
+			k = mixBlock
+		}
+		for i, sample := range input.queue[:k] {
+			m.block[i] += sample
+		}
+		input.queue = input.queue[k:]
+		input.writing = true
+		if k > n {
+			n = k
+		}
+		sources++
+	}
+	return n, sources
+}
+
+// Close stops every input and closes the sink.
+func (m *Mixer) Close() error {
+	m.mu.Lock()
+	inputs := make([]*MixerInput, 0, len(m.inputs))
+	for input := range m.inputs {
+		inputs = append(inputs, input)
+	}
+	m.mu.Unlock()
+
+	for _, input := range inputs {
+		input.Stop()
+	}
+	return m.sink.Close()
+}
+
+// MixerInput is one transmitter's share of a Mixer.
+type MixerInput struct {
+	mixer   *Mixer
+	queue   []float32 // Samples not yet mixed
+	writing bool      // Part of the block being handed to the sink
+	due     time.Time // When the last mixed sample plays, by the mix clock
+}
+
+// Start adds the input to the mix. The first input started sets the sample
+// rate of the sink; later inputs must ask for the same rate.
+func (in *MixerInput) Start(sampleRate int) error {
+	m := in.mixer
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	m.mu.Lock()
+	if m.inputs[in] {
+		m.mu.Unlock()
+		return nil
+	}
+	if m.running && sampleRate != m.sampleRate {
+		m.mu.Unlock()
+		return fmt.Errorf("mixer runs at %d Hz, not %d Hz", m.sampleRate, sampleRate)
+	}
+	running := m.running
+	m.mu.Unlock()
+
+	if !running {
+		if err := m.sink.Start(sampleRate); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inputs[in] = true
+	if !running {
+		m.sampleRate = sampleRate
+		m.running = true
+		m.stopping = false
+		m.err = nil
+		m.wake = make(chan struct{})
+		m.exited = make(chan struct{})
+		go m.run(m.wake, m.exited)
+	}
+	return nil
+}
+
+// Write queues samples to be mixed from the current position of the mix.
+func (in *MixerInput) Write(samples []float32) error {
+	m := in.mixer
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.inputs[in] {
+		return ErrStopped
+	}
+	if m.err != nil {
+		return m.err
+	}
+	in.queue = append(in.queue, samples...)
+	m.ready.Signal()
+	return nil
+}
+
+// Drain waits until the samples written to this input have been mixed
+// and are due to have played. If no other input has audio queued it then
+// drains the sink, so a lone transmitter waits for the sink's own latency
+// without transmitters on other channels waiting for each other.
+func (in *MixerInput) Drain() error {
+	m := in.mixer
+	m.mu.Lock()
+	for m.inputs[in] && (len(in.queue) > 0 || in.writing) {
+		m.mixed.Wait()
+	}
+	due := in.due
+	err := m.err
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	time.Sleep(time.Until(due))
+
+	m.mu.Lock()
+	others := m.queued()
+	m.mu.Unlock()
+	if others {
+		return nil
+	}
+	return m.sink.Drain()
+}
+
+// Stop removes the input from the mix, discarding samples not yet mixed.
+// The sink stops with the last input.
+func (in *MixerInput) Stop() error {
+	m := in.mixer
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	m.mu.Lock()
+	if !m.inputs[in] {
+		m.mu.Unlock()
+		return nil
+	}
+	delete(m.inputs, in)
+	in.queue = nil
+	in.writing = false
+	m.mixed.Broadcast()
+
+	last := len(m.inputs) == 0
+	var exited chan struct{}
+	if last {
+		m.stopping = true
+		close(m.wake)
+		m.ready.Broadcast()
+		exited = m.exited
+	}
+	m.mu.Unlock()
+
+	if !last {
+		return nil
+	}
+	err := m.sink.Stop()
+	<-exited
+
+	m.mu.Lock()
+	m.running = false
+	m.mu.Unlock()
+	return err
+}
+
+// Close stops the input. The mixer stays open.
+func (in *MixerInput) Close() error {
+	return in.Stop()
+}
\ No newline at end of file
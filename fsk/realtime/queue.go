@@ -0,0 +1,361 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// DefaultFrameGap is the silence a transmit queue leaves between frames, so
+// receivers see the carrier drop and deliver each message separately.
+const DefaultFrameGap = 200 * time.Millisecond
+
+// Transmission errors.
+var (
+	ErrCanceled    = errors.New("transmission canceled")
+	ErrStopped     = errors.New("transmit queue stopped")
+	ErrChannelBusy = errors.New("channel busy")
+)
+
+// CSMAConfig controls listen-before-talk. Before keying, a queue waits a
+// random number of slots from its contention window and senses the
+// channel. If it is busy the window doubles, up to MaxWindow, and the queue
+// tries again; after MaxRetries busy senses the frame fails with
+// ErrChannelBusy.
+type CSMAConfig struct {
+	SlotTime   time.Duration // Backoff unit
+	MinWindow  int           // Slots in the first contention window
+	MaxWindow  int           // Largest contention window
+	MaxRetries int           // Busy senses tolerated per frame
+}
+
+// DefaultCSMAConfig returns backoff settings suited to messages of a
+// second or two.
+func DefaultCSMAConfig() CSMAConfig {
+	return CSMAConfig{
+		SlotTime:   50 * time.Millisecond,
+		MinWindow:  4,
+		MaxWindow:  64,
+		MaxRetries: 8,
+	}
+}
+
+// Transmission is a frame waiting in, or sent by, a TransmitQueue.
+type Transmission struct {
+	ID       uint64
+	Data     []byte
+	Backoffs int // Times the frame found the channel busy, valid after Done
+	done     chan struct{}
+	err      error
+}
+
+// Done returns a channel that is closed once the frame has been played,
+// canceled or has failed.
+func (t *Transmission) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err returns why the frame did not play, or nil once it has played. It is
+// only meaningful after Done is closed.
+func (t *Transmission) Err() error {
+	<-t.done
+	return t.err
+}
+
+// Wait blocks until the frame completes or ctx ends.
+func (t *Transmission) Wait(ctx context.Context) error {
+	select {
+	case <-t.done:
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TransmitQueue plays frames through a sink one after another, in the order
+// they were sent, with a gap of silence between them.
+type TransmitQueue struct {
+	modem      *core.Modem
+	sink       AudioSink
+	mu         sync.Mutex
+	ready      *sync.Cond
+	frames     []*Transmission
+	nextID     uint64
+	gap        time.Duration
+	onComplete func(id uint64, err error)
+	onTransmit func(t *Transmission, active bool)
+	busy       func() bool // Carrier sense, nil without listen-before-talk
+	csma       CSMAConfig
+	running    bool
+	stopping   bool
+	exited     chan struct{}
+	wake       chan struct{} // Interrupts the frame gap on Stop
+}
+
+// NewTransmitQueue creates a queue that encodes frames with modem and plays
+// them into sink. Frames are queued immediately but only play once the
+// queue is started.
+func NewTransmitQueue(modem *core.Modem, sink AudioSink) *TransmitQueue {
+	q := &TransmitQueue{
+		modem: modem,
+		sink:  sink,
+		gap:   DefaultFrameGap,
+	}
+	q.ready = sync.NewCond(&q.mu)
+	return q
+}
+
+// SetFrameGap sets the silence left after each frame.
+func (q *TransmitQueue) SetFrameGap(gap time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.gap = gap
+}
+
+// OnComplete sets a callback run whenever a frame has played, been canceled
+// or failed. It runs on the queue goroutine for frames that played, and on
+// the caller of Cancel or Stop for frames removed from the queue.
+func (q *TransmitQueue) OnComplete(callback func(id uint64, err error)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onComplete = callback
+}
+
+// OnTransmit sets a callback run on the queue goroutine when a frame starts
+// playing (active true) and when it has finished (active false), for
+// keying or muting around transmissions.
+func (q *TransmitQueue) OnTransmit(callback func(t *Transmission, active bool)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onTransmit = callback
+}
+
+// SetCarrierSense enables listen-before-talk with busy reporting whether
+// another station is on the channel. A nil busy disables it.
+func (q *TransmitQueue) SetCarrierSense(busy func() bool, config CSMAConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.busy = busy
+	q.csma = config
+}
+
+// Start opens the sink and begins playing queued frames. Starting a
+// running queue does nothing.
+func (q *TransmitQueue) Start() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.running {
+		return nil
+	}
+	if err := q.sink.Start(q.modem.Config().SampleRate); err != nil {
+		return err
+	}
+
+	q.running = true
+	q.stopping = false
+	q.exited = make(chan struct{})
+	q.wake = make(chan struct{})
+	go q.run(q.exited)
+	return nil
+}
+
+// Send queues data and returns at once. The returned Transmission reports
+// when the frame completes.
+func (q *TransmitQueue) Send(data []byte) *Transmission {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	t := &Transmission{
+		ID:   q.nextID,
+		Data: append([]byte(nil), data...),
+		done: make(chan struct{}),
+	}
+	q.frames = append(q.frames, t)
+	q.ready.Signal()
+	return t
+}
+
+// SendAndWait queues data and blocks until it has played. If ctx ends
+// while the frame is still queued, the frame is canceled.
+func (q *TransmitQueue) SendAndWait(ctx context.Context, data []byte) error {
+	t := q.Send(data)
+	err := t.Wait(ctx)
+	if err != nil && ctx.Err() != nil {
+		q.Cancel(t.ID)
+	}
+	return err
+}
+
+// Cancel removes a frame that has not started playing. It reports whether
+// the frame was found; a canceled frame completes with ErrCanceled.
+func (q *TransmitQueue) Cancel(id uint64) bool {
+	q.mu.Lock()
+	var canceled *Transmission
+	for i, t := range q.frames {
+		if t.ID == id {
+			canceled = t
+			q.frames = append(q.frames[:i], q.frames[i+1:]...)
+			break
+		}
+	}
+	q.mu.Unlock()
+
+	if canceled == nil {
+		return false
+	}
+	q.complete(canceled, ErrCanceled)
+	return true
+}
+
+// CancelAll removes every frame that has not started playing and returns
+// how many there were.
+func (q *TransmitQueue) CancelAll() int {
+	return len(q.drop(ErrCanceled))
+}
+
+// Pending returns the number of frames waiting to play, not counting the
+// one playing.
+func (q *TransmitQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.frames)
+}
+
+// Stop cuts off the frame playing, fails the queued frames with ErrStopped
+// and closes the sink.
+func (q *TransmitQueue) Stop() {
+	q.mu.Lock()
+	if !q.running {
+		q.mu.Unlock()
+		q.drop(ErrStopped)
+		return
+	}
+	q.stopping = true
+	close(q.wake)
+	q.ready.Signal()
+	exited := q.exited
+	q.mu.Unlock()
+
+	q.drop(ErrStopped)
+	q.sink.Stop()
+	<-exited
+
+	q.mu.Lock()
+	q.running = false
+	q.mu.Unlock()
+}
+
+// run plays frames until the queue is stopped.
+func (q *TransmitQueue) run(exited chan struct{}) {
+	defer close(exited)
+
+	for {
+		q.mu.Lock()
+		for len(q.frames) == 0 && !q.stopping {
+			q.ready.Wait()
+		}
+		if q.stopping {
+			q.mu.Unlock()
+			return
+		}
+		t := q.frames[0]
+		q.frames = q.frames[1:]
+		gap := q.gap
+		wake := q.wake
+		onTransmit := q.onTransmit
+		busy := q.busy
+		csma := q.csma
+		q.mu.Unlock()
+
+		if busy != nil {
+			if err := q.contend(t, busy, csma, wake); err != nil {
+				q.complete(t, err)
+				continue
+			}
+		}
+
+		if onTransmit != nil {
+			onTransmit(t, true)
+		}
+		err := q.sink.Write(q.modem.Encode(t.Data))
+		if err == nil {
+			err = q.sink.Drain()
+		}
+		if onTransmit != nil {
+			onTransmit(t, false)
+		}
+
+		q.mu.Lock()
+		if err == nil && q.stopping {
+			err = ErrStopped
+		}
+		q.mu.Unlock()
+		q.complete(t, err)
+
+		select {
+		case <-time.After(gap):
+		case <-wake:
+		}
+	}
+}
+
+// contend waits until the channel is clear, backing off while it is busy.
+func (q *TransmitQueue) contend(t *Transmission, busy func() bool, csma CSMAConfig, wake chan struct{}) error {
+	window := csma.MinWindow
+	for {
+		if window > 0 {
+			select {
+			case <-time.After(time.Duration(rand.Intn(window)) * csma.SlotTime):
+			case <-wake:
+				return ErrStopped
+			}
+		}
+		if !busy() {
+			return nil
+		}
+
+		t.Backoffs++
+		if t.Backoffs > csma.MaxRetries {
+			return ErrChannelBusy
+		}
+		window *= 2
+		if window < 1 {
+			window = 1
+		}
+		if window > csma.MaxWindow {
+			window = csma.MaxWindow
+		}
+	}
+}
+
+// drop removes all queued frames and completes them with err.
+func (q *TransmitQueue) drop(err error) []*Transmission {
+	q.mu.Lock()
+	dropped := q.frames
+	q.frames = nil
+	q.mu.Unlock()
+
+	for _, t := range dropped {
+		q.complete(t, err)
+	}
+	return dropped
+}
+
+func (q *TransmitQueue) complete(t *Transmission, err error) {
+	t.err = err
+	close(t.done)
+
+	q.mu.Lock()
+	callback := q.onComplete
+	q.mu.Unlock()
+	if callback != nil {
+		callback(t.ID, err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,154 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// recordSink keeps every block written to it.
+type recordSink struct {
+	mu     sync.Mutex
+	blocks [][]float32
+}
+
+func (s *recordSink) Start(sampleRate int) error { return nil }
+func (s *recordSink) Drain() error               { return nil }
+func (s *recordSink) Stop() error                { return nil }
+func (s *recordSink) Close() error               { return nil }
+
+func (s *recordSink) Write(samples []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks = append(s.blocks, append([]float32(nil), samples...))
+	return nil
+}
+
+func TestTransmitQueueOrder(t *testing.T) {
+	modem := core.New(core.DefaultConfig())
+	frames := []string{"first", "second", "third"}
+
+	sink := &recordSink{}
+	queue := NewTransmitQueue(modem, sink)
+	queue.SetFrameGap(0)
+	var transmissions []*Transmission
+	for _, frame := range frames {
+		transmissions = append(transmissions, queue.Send([]byte(frame)))
+	}
+	if n := queue.Pending(); n != len(frames) {
+		t.Errorf("Pending() before Start = %d, want %d", n, len(frames))
+	}
+
+	if err := queue.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Stop()
+	for _, transmission := range transmissions {
+		if err := transmission.Wait(context.Background()); err != nil {
+			t.Fatalf("frame %d completed with %v", transmission.ID, err)
+		}
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.blocks) != len(frames) {
+		t.Fatalf("sink received %d blocks, want %d", len(sink.blocks), len(frames))
+	}
+	for i, frame := range frames {
+		if got := modem.Decode(sink.blocks[i]); string(got) != frame {
+			t.Errorf("block %d decodes to %q, want %q", i, got, frame)
+		}
+	}
+}
+
+func TestTransmitQueueCancel(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel int // Frame to cancel, -1 for none
+		all    bool
+		want   []error
+	}{
+		{"cancel middle", 1, false, []error{ErrStopped, ErrCanceled, ErrStopped}},
+		{"cancel all", -1, true, []error{ErrCanceled, ErrCanceled, ErrCanceled}},
+		{"stop", -1, false, []error{ErrStopped, ErrStopped, ErrStopped}},
+	}
+
+	for _, tt := range tests {
+		queue := NewTransmitQueue(core.New(core.DefaultConfig()), &recordSink{})
+		var completed []error
+		queue.OnComplete(func(id uint64, err error) { completed = append(completed, err) })
+
+		var transmissions []*Transmission
+		var ids []uint64
+		for _, frame := range []string{"a", "b", "c"} {
+			transmission := queue.Send([]byte(frame))
+			transmissions = append(transmissions, transmission)
+			ids = append(ids, transmission.ID)
+		}
+		if tt.cancel >= 0 {
+			queue.Cancel(ids[tt.cancel])
+		}
+		if tt.all {
+			queue.CancelAll()
+		}
+		queue.Stop()
+
+		for i, transmission := range transmissions {
+			if err := transmission.Err(); !errors.Is(err, tt.want[i]) {
+				t.Errorf("%s: frame %d completed with %v, want %v", tt.name, i, err, tt.want[i])
+			}
+		}
+		if len(completed) != len(transmissions) {
+			t.Errorf("%s: OnComplete ran %d times, want %d", tt.name, len(completed), len(transmissions))
+		}
+		if queue.Cancel(ids[0]) {
+			t.Errorf("%s: Cancel of a completed frame reported true", tt.name)
+		}
+	}
+}
+
+func TestTransmitQueueCarrierSense(t *testing.T) {
+	csma := CSMAConfig{SlotTime: time.Millisecond, MinWindow: 1, MaxWindow: 4, MaxRetries: 3}
+	tests := []struct {
+		name     string
+		busyFor  int // Senses that find the channel busy
+		want     error
+		backoffs int
+	}{
+		{"clear", 0, nil, 0},
+		{"busy twice", 2, nil, 2},
+		{"busy up to the limit", 3, nil, 3},
+		{"always busy", 100, ErrChannelBusy, 4},
+	}
+
+	for _, tt := range tests {
+		sink := &recordSink{}
+		queue := NewTransmitQueue(core.New(core.DefaultConfig()), sink)
+		senses := 0
+		queue.SetCarrierSense(func() bool {
+			senses++
+			return senses <= tt.busyFor
+		}, csma)
+		if err := queue.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		transmission := queue.Send([]byte("hello"))
+		if err := transmission.Wait(context.Background()); !errors.Is(err, tt.want) {
+			t.Errorf("%s: frame completed with %v, want %v", tt.name, err, tt.want)
+		}
+		if transmission.Backoffs != tt.backoffs {
+			t.Errorf("%s: Backoffs = %d, want %d", tt.name, transmission.Backoffs, tt.backoffs)
+		}
+		sink.mu.Lock()
+		if played := len(sink.blocks) > 0; played != (tt.want == nil) {
+			t.Errorf("%s: frame played %v, want %v", tt.name, played, tt.want == nil)
+		}
+		sink.mu.Unlock()
+		queue.Stop()
+	}
+}
\ No newline at end of file
@@ -1,95 +1,315 @@
 // Package realtime provides real-time audio I/O functionality for FSK communication.
-// This package depends on malgo for cross-platform audio support.
+// Audio goes through the AudioSource and AudioSink interfaces; the default
+// implementations use malgo for cross-platform sound card support.
 package realtime
 
 import (
-	"encoding/binary"
-	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/gen2brain/malgo"
 	"github.com/gleicon/go-fsk/fsk/core"
 )
 
-// Receiver handles real-time audio capture and decoding.
+// receiverBuffer is how much captured audio a receiver holds ahead of its
+// decoder before it starts dropping samples.
+const receiverBuffer = 2 * time.Second
+
+// decodeBlockSize is the number of samples the decode goroutine takes from
+// the ring at a time.
+const decodeBlockSize = 4096
+
+// prerollBlocks is how many carrier detector blocks before a rising carrier
+// are passed to the decoder, so it sees the start of the transmission.
+const prerollBlocks = 4
+
+// Receiver handles real-time audio capture and decoding. The audio callback
+// only copies samples into a lock-free ring; a separate goroutine decodes
+// them and runs the callback.
+//
+// A carrier detector squelches the decoder: audio is only decoded while the
+// modem's tones are present, and each transmission is flushed when its
+// carrier falls.
 type Receiver struct {
-	modem    *core.Modem
-	ctx      *malgo.AllocatedContext
-	device   *malgo.Device
-	samples  []float32
-	mu       sync.Mutex
-	callback func([]byte) // Callback for decoded data
+	modem     *core.Modem
+	decoder   *core.StreamDecoder
+	carrier   *core.CarrierDetector
+	events    []core.CarrierEvent // Scratch buffer for carrier
+	open      bool                // Samples are passed to the decoder
+	history   []float32           // Recent samples while the squelch is closed
+	held      []float32           // Samples not yet decoded while it is open
+	preroll   int                 // Samples kept in history
+	holdback  int                 // Samples kept in held
+	position  int                 // Samples fed to carrier
+	onCarrier func(core.CarrierEvent)
+	source    AudioSource
+	unpaced   bool // Source may wait for ring space
+	ring      *ringBuffer
+	block     []float32     // Scratch buffer for the decode goroutine
+	wake      chan struct{} // Samples were written to the ring
+	space     chan struct{} // Samples were read from the ring
+	stop      chan struct{}
+	exited    chan struct{}
+	overruns  atomic.Uint64
+	dropped   atomic.Uint64
+	mu        sync.Mutex
+	callback  func([]byte) // Callback for decoded data
 }
 
-// NewReceiver creates a new real-time receiver.
+// NewReceiver creates a new real-time receiver on the default capture device.
 func NewReceiver(modem *core.Modem, callback func([]byte)) (*Receiver, error) {
-	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
-		// Audio system messages (optional logging)
-	})
+	source, err := NewMalgoSource()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize audio context: %v", err)
+		return nil, err
 	}
+	return NewReceiverWithSource(modem, source, callback), nil
+}
 
-	return &Receiver{
+// NewReceiverWithSource creates a receiver that decodes audio from source.
+func NewReceiverWithSource(modem *core.Modem, source AudioSource, callback func([]byte)) *Receiver {
+	_, unpaced := source.(Unpaced)
+	size := int(float64(modem.Config().SampleRate) * receiverBuffer.Seconds())
+
+	r := &Receiver{
 		modem:    modem,
-		ctx:      ctx,
+		decoder:  core.NewStreamDecoder(modem),
+		source:   source,
+		unpaced:  unpaced,
+		ring:     newRingBuffer(size),
+		block:    make([]float32, decodeBlockSize),
+		wake:     make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
 		callback: callback,
-	}, nil
+	}
+	r.squelch(core.NewCarrierDetector(modem, core.DefaultCarrierConfig()))
+	return r
 }
 
 // Start begins real-time audio capture and decoding.
 func (r *Receiver) Start() error {
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
-	deviceConfig.Capture.Format = malgo.FormatS16
-	deviceConfig.Capture.Channels = 1
-	deviceConfig.SampleRate = uint32(r.modem.Config().SampleRate)
-	deviceConfig.Alsa.NoMMap = 1
-
-	onRecvFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
-		r.mu.Lock()
-		defer r.mu.Unlock()
-
-		// Convert int16 samples to float32
-		for i := 0; i < len(pInputSamples); i += 2 {
-			sample := int16(binary.LittleEndian.Uint16(pInputSamples[i : i+2]))
-			floatSample := float32(sample) / 32767.0
-			r.samples = append(r.samples, floatSample)
+	if r.stop != nil {
+		return nil
+	}
+
+	r.ring.Reset()
+	r.stop = make(chan struct{})
+	r.exited = make(chan struct{})
+	go r.decode(r.stop, r.exited)
+
+	if err := r.source.Start(r.modem.Config().SampleRate, r.onSamples); err != nil {
+		close(r.stop)
+		<-r.exited
+		r.stop = nil
+		return err
+	}
+	return nil
+}
+
+// onSamples runs on the audio thread. It never waits for the decoder unless
+// the source is unpaced; samples that do not fit are counted as an overrun.
+func (r *Receiver) onSamples(samples []float32) {
+	if dropped := r.ring.fill(samples, r.unpaced, r.wake, r.space, r.stop); dropped > 0 {
+		r.overruns.Add(1)
+		r.dropped.Add(uint64(dropped))
+	}
+}
+
+// decode consumes the ring until stop is closed, then drains what is left.
+func (r *Receiver) decode(stop, exited chan struct{}) {
+	defer close(exited)
+
+	for {
+		if r.process() {
+			continue
+		}
+		select {
+		case <-r.wake:
+		case <-stop:
+			for r.process() {
+			}
+			return
 		}
+	}
+}
+
+// process decodes one block from the ring and reports whether there was one.
+func (r *Receiver) process() bool {
+	n := r.ring.Read(r.block)
+	if n == 0 {
+		return false
+	}
+	notify(r.space)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-		// Try to decode if we have enough samples
-		if len(r.samples) >= r.modem.SymbolPeriod()*4 { // At least 4 symbols
-			decoded := r.modem.Decode(r.samples)
-			if len(decoded) > 0 && r.callback != nil {
-				r.callback(decoded)
+	r.feed(r.block[:n])
+	return true
+}
+
+// feed passes samples to the carrier detector and decodes them while the
+// carrier is up. r.mu must be held.
+func (r *Receiver) feed(samples []float32) {
+	start := r.position
+	r.position += len(samples)
+	r.events = r.carrier.Process(samples, r.events[:0])
+
+	cut := 0
+	for _, event := range r.events {
+		at := event.Detected - start
+		r.route(samples[cut:at])
+		cut = at
+
+		if event.Present {
+			r.open = true
+			r.held = append(r.held[:0], r.history...)
+			r.history = r.history[:0]
+		} else {
+			// Decode up to the end of the carrier; the rest is noise
+			end := len(r.held) - (event.Detected - event.Offset)
+			if end < 0 {
+				end = 0
 			}
-			r.samples = r.samples[:0] // Clear buffer
+			r.decoder.WriteSamples(r.held[:end])
+			r.history = append(r.history[:0], r.held[end:]...)
+			r.held = r.held[:0]
+			r.close()
+		}
+		if r.onCarrier != nil {
+			r.onCarrier(event)
 		}
 	}
+	r.route(samples[cut:])
+	r.deliver()
+}
 
-	device, err := malgo.InitDevice(r.ctx.Context, deviceConfig, malgo.DeviceCallbacks{
-		Data: onRecvFrames,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to initialize capture device: %v", err)
+// route decodes samples while the squelch is open and otherwise keeps the
+// most recent ones for the next rising carrier. r.mu must be held.
+func (r *Receiver) route(samples []float32) {
+	if r.open {
+		// Hold back the samples a falling carrier may still disown
+		r.held = append(r.held, samples...)
+		if ready := len(r.held) - r.holdback; ready > 0 {
+			r.decoder.WriteSamples(r.held[:ready])
+			r.held = r.held[:copy(r.held, r.held[ready:])]
+		}
+		return
+	}
+
+	r.history = append(r.history, samples...)
+	if excess := len(r.history) - r.preroll; excess > 0 {
+		r.history = r.history[:copy(r.history, r.history[excess:])]
+	}
+}
+
+// close ends the transmission being decoded and delivers what is left of
+// it. r.mu must be held.
+func (r *Receiver) close() {
+	r.open = false
+	r.decoder.Flush()
+	r.deliver()
+	r.decoder.Reset()
+}
+
+// squelch installs a carrier detector and clears the squelch state. r.mu
+// must be held.
+func (r *Receiver) squelch(carrier *core.CarrierDetector) {
+	r.carrier = carrier
+	r.position = 0
+	r.preroll = prerollBlocks * carrier.BlockSize()
+	r.holdback = (carrier.Config().Hang + 1) * carrier.BlockSize()
+	r.history = r.history[:0]
+	r.held = r.held[:0]
+}
+
+// deliver passes the decoded bytes to the callback. r.mu must be held.
+func (r *Receiver) deliver() {
+	if n := r.decoder.Buffered(); n > 0 {
+		decoded := make([]byte, n)
+		r.decoder.Read(decoded)
+		if r.callback != nil {
+			r.callback(decoded)
+		}
+	}
+}
+
+// SetCarrierConfig replaces the thresholds of the carrier detector and of
+// the decoder's own lock. The zero CarrierConfig disables the squelch.
+func (r *Receiver) SetCarrierConfig(config core.CarrierConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.open {
+		r.decoder.WriteSamples(r.held)
+		r.close()
 	}
+	r.decoder.SetCarrierConfig(config)
+	r.squelch(core.NewCarrierDetector(r.modem, config))
+	return nil
+}
+
+// OnCarrier sets a callback run on the decode goroutine whenever the
+// carrier rises or falls.
+func (r *Receiver) OnCarrier(callback func(event core.CarrierEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onCarrier = callback
+}
+
+// CarrierPresent reports whether the receiver is hearing a carrier.
+func (r *Receiver) CarrierPresent() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.open
+}
 
-	r.device = device
-	return r.device.Start()
+// Overruns returns how many captured blocks did not fit in the buffer
+// because the decoder fell behind.
+func (r *Receiver) Overruns() uint64 {
+	return r.overruns.Load()
 }
 
-// Stop stops the real-time receiver.
+// DroppedSamples returns the total number of samples lost to overruns.
+func (r *Receiver) DroppedSamples() uint64 {
+	return r.dropped.Load()
+}
+
+// Stop stops the real-time receiver, decodes everything already captured
+// and delivers any bytes still held by the decoder.
 func (r *Receiver) Stop() {
-	if r.device != nil {
-		r.device.Stop()
-		r.device.Uninit()
+	r.source.Stop()
+
+	if r.stop != nil {
+		close(r.stop)
+		<-r.exited
+		r.stop = nil
 	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.open {
+		r.decoder.WriteSamples(r.held)
+		r.close()
+	}
+	r.carrier.Reset()
+	r.squelch(r.carrier)
 }
 
 // Close cleans up resources.
 func (r *Receiver) Close() {
 	r.Stop()
-	if r.ctx != nil {
-		r.ctx.Uninit()
-		r.ctx.Free()
+	r.source.Close()
+}
+
+// notify wakes the goroutine waiting on ch without blocking.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
 	}
 }
\ No newline at end of file
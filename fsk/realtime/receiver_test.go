@@ -0,0 +1,113 @@
+package realtime
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// collector gathers the bytes a receiver delivers.
+type collector struct {
+	mu       sync.Mutex
+	received []byte
+}
+
+func (c *collector) add(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.received = append(c.received, data...)
+}
+
+func (c *collector) bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.received...)
+}
+
+// waitFor polls until c holds want or a few seconds have passed.
+func (c *collector) waitFor(want []byte) []byte {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && !bytes.Equal(c.bytes(), want) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return c.bytes()
+}
+
+func TestReceiverLoopback(t *testing.T) {
+	tests := []struct {
+		name   string
+		config core.Config
+		frames []string
+	}{
+		{"default", core.DefaultConfig(), []string{"hello"}},
+		{"ultrasonic", core.UltrasonicConfig(), []string{"hello", "world"}},
+		{"Bell 103", core.Bell103Config(), []string{"Data"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.TimingRecovery = true
+			modem := core.New(config)
+			loopback := NewLoopback()
+
+			received := &collector{}
+			receiver := NewReceiverWithSource(modem, loopback.Source(), received.add)
+			if err := receiver.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer receiver.Close()
+			transmitter := NewTransmitterWithSink(modem, loopback.Sink())
+			defer transmitter.Close()
+
+			var want []byte
+			for _, frame := range tt.frames {
+				if err := transmitter.Transmit([]byte(frame)); err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, frame...)
+			}
+			if got := received.waitFor(want); !bytes.Equal(got, want) {
+				t.Errorf("received %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReceiverPCMSource(t *testing.T) {
+	modem := core.New(core.DefaultConfig())
+	message := []byte("over a pipe")
+
+	// Stop delivers what is held once the input runs out mid-transmission
+	tests := []struct {
+		name    string
+		silence int
+	}{
+		{"with trailing silence", modem.Config().SampleRate / 2},
+		{"ending on the signal", 0},
+	}
+	for _, tt := range tests {
+		var pcm bytes.Buffer
+		sink := NewPCMSink(&pcm)
+		signal := append(modem.Encode(message), make([]float32, tt.silence)...)
+		if err := sink.Write(signal); err != nil {
+			t.Fatal(err)
+		}
+
+		source := NewPCMSource(&pcm)
+		received := &collector{}
+		receiver := NewReceiverWithSource(modem, source, received.add)
+		if err := receiver.Start(); err != nil {
+			t.Fatal(err)
+		}
+		<-source.Done()
+		receiver.Close()
+
+		if got := received.bytes(); !bytes.Equal(got, message) {
+			t.Errorf("%s: received %q, want %q", tt.name, got, message)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,90 @@
+package realtime
+
+import "sync/atomic"
+
+// ringBuffer is a single-producer, single-consumer queue of samples. Neither
+// side locks or blocks: the producer only advances head and the consumer
+// only advances tail, so an audio callback can write while a decode
+// goroutine reads.
+type ringBuffer struct {
+	samples []float32
+	mask    uint64
+	head    atomic.Uint64 // Total samples written
+	tail    atomic.Uint64 // Total samples read
+}
+
+// newRingBuffer creates a ring holding at least size samples. The capacity
+// is rounded up to a power of two.
+func newRingBuffer(size int) *ringBuffer {
+	capacity := 1
+	for capacity < size {
+		capacity <<= 1
+	}
+	return &ringBuffer{
+		samples: make([]float32, capacity),
+		mask:    uint64(capacity - 1),
+	}
+}
+
+// Write copies as many samples as fit and returns how many were written.
+// Only the producer may call it.
+func (r *ringBuffer) Write(p []float32) int {
+	head := r.head.Load()
+	free := uint64(len(r.samples)) - (head - r.tail.Load())
+	n := uint64(len(p))
+	if n > free {
+		n = free
+	}
+
+	start := head & r.mask
+	copied := uint64(copy(r.samples[start:], p[:n]))
+	copy(r.samples, p[copied:n])
+
+	r.head.Store(head + n)
+	return int(n)
+}
+
+// Read moves up to len(p) samples into p and returns how many were read.
+// Only the consumer may call it.
+func (r *ringBuffer) Read(p []float32) int {
+	tail := r.tail.Load()
+	n := r.head.Load() - tail
+	if n > uint64(len(p)) {
+		n = uint64(len(p))
+	}
+
+	start := tail & r.mask
+	copied := uint64(copy(p[:n], r.samples[start:]))
+	copy(p[copied:n], r.samples)
+
+	r.tail.Store(tail + n)
+	return int(n)
+}
+
+// fill writes samples from an audio callback and wakes the consumer
+// through wake. An unpaced producer waits on space for room until stop is
+// closed; otherwise the samples that do not fit are dropped. It returns how
+// many were dropped.
+func (r *ringBuffer) fill(samples []float32, unpaced bool, wake, space, stop chan struct{}) int {
+	for {
+		n := r.Write(samples)
+		samples = samples[n:]
+		if n > 0 {
+			notify(wake)
+		}
+		if len(samples) == 0 || !unpaced {
+			return len(samples)
+		}
+		select {
+		case <-space:
+		case <-stop:
+			return 0
+		}
+	}
+}
+
+// Reset empties the ring. Neither side may be active.
+func (r *ringBuffer) Reset() {
+	r.head.Store(0)
+	r.tail.Store(0)
+}
\ No newline at end of file
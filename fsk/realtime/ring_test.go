@@ -0,0 +1,43 @@
+package realtime
+
+import "testing"
+
+func TestRingBuffer(t *testing.T) {
+	ring := newRingBuffer(5) // Rounded up to 8
+	block := make([]float32, 8)
+
+	tests := []struct {
+		write int // Samples to write, counting on from the last write
+		read  int // Samples to read afterwards
+		wrote int
+		got   int
+	}{
+		{6, 4, 6, 4},
+		{5, 0, 5, 0}, // Wraps around the end
+		{3, 8, 1, 8}, // Full, only one sample fits
+		{0, 8, 0, 0},
+	}
+
+	next, want := float32(0), float32(0)
+	for i, tt := range tests {
+		samples := make([]float32, tt.write)
+		for j := range samples {
+			samples[j] = next + float32(j)
+		}
+		if n := ring.Write(samples); n != tt.wrote {
+			t.Errorf("step %d: Write(%d samples) = %d, want %d", i, tt.write, n, tt.wrote)
+		}
+		next += float32(tt.wrote)
+
+		n := ring.Read(block[:tt.read])
+		if n != tt.got {
+			t.Errorf("step %d: Read(%d) = %d, want %d", i, tt.read, n, tt.got)
+		}
+		for _, sample := range block[:n] {
+			if sample != want {
+				t.Errorf("step %d: read %v, want %v", i, sample, want)
+			}
+			want++
+		}
+	}
+}
\ No newline at end of file
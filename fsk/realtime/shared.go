@@ -0,0 +1,237 @@
+package realtime
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// sharedSourceBuffer is how much capture a shared source holds ahead of its
+// channelizer before it starts dropping samples.
+const sharedSourceBuffer = 2 * time.Second
+
+// SharedSource lets many consumers listen to one capture stream. Each
+// consumer gets a SourceTap, an AudioSource of its own. A tap for a band
+// hears the capture with the bands of the other taps removed by a shared
+// core.Channelizer, so a channel's receiver is not disturbed by its
+// neighbours. The underlying source is started with the first tap and
+// stopped with the last, so a single capture device can serve every
+// channel of a MultiChannelChat.
+//
+// Like a Receiver, the audio callback only copies samples into a lock-free
+// ring; a separate goroutine runs the channelizer and feeds the taps.
+type SharedSource struct {
+	source      AudioSource
+	unpaced     bool       // Source may wait for ring space
+	state       sync.Mutex // Serializes starting and stopping taps
+	mu          sync.Mutex
+	sampleRate  int
+	running     bool
+	taps        map[*SourceTap]bool // Started taps
+	channelizer *core.Channelizer
+	ring        *ringBuffer
+	block       []float32     // Scratch buffer for the fan-out goroutine
+	wake        chan struct{} // Samples were written to the ring
+	space       chan struct{} // Samples were read from the ring
+	stop        chan struct{}
+	exited      chan struct{}
+	overruns    atomic.Uint64
+}
+
+// NewSharedSource creates a shared source reading from source.
+func NewSharedSource(source AudioSource) *SharedSource {
+	_, unpaced := source.(Unpaced)
+	return &SharedSource{
+		source:  source,
+		unpaced: unpaced,
+		taps:    make(map[*SourceTap]bool),
+		block:   make([]float32, decodeBlockSize),
+		wake:    make(chan struct{}, 1),
+		space:   make(chan struct{}, 1),
+	}
+}
+
+// Tap returns a new source for the band from low to high Hz. It delivers
+// the capture with the bands of the other started taps removed, rather
+// than just its own band: noise then keeps its broadband character, which
+// the tone purity measurements of decoders and carrier detectors rely on.
+// Filtered audio arrives in blocks of several thousand samples, lagging the
+// capture by up to a few hundred milliseconds. A high of zero delivers the
+// capture unfiltered and without delay.
+func (s *SharedSource) Tap(low, high float64) *SourceTap {
+	return &SourceTap{shared: s, low: low, high: high}
+}
+
+// onSamples runs on the audio thread. It never waits for the fan-out
+// goroutine unless the source is unpaced; samples that do not fit are
+// counted as an overrun.
+func (s *SharedSource) onSamples(samples []float32) {
+	if s.ring.fill(samples, s.unpaced, s.wake, s.space, s.stop) > 0 {
+		s.overruns.Add(1)
+	}
+}
+
+// fanOut consumes the ring until stop is closed.
+func (s *SharedSource) fanOut(stop, exited chan struct{}) {
+	defer close(exited)
+
+	for {
+		if n := s.ring.Read(s.block); n > 0 {
+			notify(s.space)
+			s.deliver(s.block[:n])
+			continue
+		}
+		select {
+		case <-s.wake:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// deliver passes a captured block to every started tap.
+func (s *SharedSource) deliver(samples []float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tap := range s.taps {
+		if tap.band == nil {
+			tap.onSamples(samples)
+		}
+	}
+	if s.channelizer.Bands() > 0 {
+		s.channelizer.Process(samples, s.emit)
+	}
+}
+
+// emit delivers the output of a band to its tap. s.mu must be held.
+func (s *SharedSource) emit(band *core.Band, samples []float32) {
+	for tap := range s.taps {
+		if tap.band == band {
+			tap.onSamples(samples)
+			return
+		}
+	}
+}
+
+// Overruns returns how many captured blocks did not fit in the buffer
+// because the taps fell behind.
+func (s *SharedSource) Overruns() uint64 {
+	return s.overruns.Load()
+}
+
+// Close stops every tap and closes the underlying source.
+func (s *SharedSource) Close() error {
+	s.mu.Lock()
+	taps := make([]*SourceTap, 0, len(s.taps))
+	for tap := range s.taps {
+		taps = append(taps, tap)
+	}
+	s.mu.Unlock()
+
+	for _, tap := range taps {
+		tap.Stop()
+	}
+	return s.source.Close()
+}
+
+// SourceTap is one consumer of a SharedSource.
+type SourceTap struct {
+	shared    *SharedSource
+	low, high float64
+	band      *core.Band // nil for the full band
+	onSamples func(samples []float32)
+}
+
+// Start begins delivering samples. The first tap started sets the sample
+// rate of the shared source; later taps must ask for the same rate.
+func (t *SourceTap) Start(sampleRate int, onSamples func(samples []float32)) error {
+	s := t.shared
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mu.Lock()
+	if s.taps[t] {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.running && sampleRate != s.sampleRate {
+		s.mu.Unlock()
+		return fmt.Errorf("shared source runs at %d Hz, not %d Hz", s.sampleRate, sampleRate)
+	}
+	starting := !s.running
+	if starting {
+		s.sampleRate = sampleRate
+		s.channelizer = core.NewChannelizer(sampleRate)
+	}
+	t.onSamples = onSamples
+	t.band = nil
+	if t.high > 0 {
+		t.band = s.channelizer.AddIsolated(t.low, t.high)
+	}
+	s.taps[t] = true
+	s.running = true
+	s.mu.Unlock()
+
+	if starting {
+		s.ring = newRingBuffer(int(float64(sampleRate) * sharedSourceBuffer.Seconds()))
+		s.stop = make(chan struct{})
+		s.exited = make(chan struct{})
+		go s.fanOut(s.stop, s.exited)
+
+		if err := s.source.Start(sampleRate, s.onSamples); err != nil {
+			s.halt()
+			s.mu.Lock()
+			delete(s.taps, t)
+			s.running = false
+			s.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// halt ends the fan-out goroutine. s.state must be held.
+func (s *SharedSource) halt() {
+	close(s.stop)
+	<-s.exited
+	s.stop = nil
+}
+
+// Stop ends delivery to this tap. The underlying source stops with the
+// last tap.
+func (t *SourceTap) Stop() error {
+	s := t.shared
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mu.Lock()
+	if !s.taps[t] {
+		s.mu.Unlock()
+		return nil
+	}
+	delete(s.taps, t)
+	if t.band != nil {
+		s.channelizer.Remove(t.band)
+	}
+	last := len(s.taps) == 0
+	if last {
+		s.running = false
+	}
+	s.mu.Unlock()
+
+	if last {
+		err := s.source.Stop()
+		s.halt()
+		return err
+	}
+	return nil
+}
+
+// Close stops the tap. The shared source stays open.
+func (t *SourceTap) Close() error {
+	return t.Stop()
+}
\ No newline at end of file
@@ -0,0 +1,64 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+)
+
+// captureSource hands its callback to the test, which plays the audio
+// thread.
+type captureSource struct {
+	onSamples func(samples []float32)
+}
+
+func (s *captureSource) Start(sampleRate int, onSamples func(samples []float32)) error {
+	s.onSamples = onSamples
+	return nil
+}
+
+func (s *captureSource) Stop() error  { return nil }
+func (s *captureSource) Close() error { return nil }
+
+func TestSharedSourceCaptureThread(t *testing.T) {
+	capture := &captureSource{}
+	shared := NewSharedSource(capture)
+
+	// A tap that does not return must not hold up the capture callback
+	blocked := make(chan struct{})
+	delivered := make(chan struct{}, 1)
+	tap := shared.Tap(0, 0)
+	if err := tap.Start(48000, func(samples []float32) {
+		notify(delivered)
+		<-blocked
+	}); err != nil {
+		t.Fatal(err)
+	}
+	band := shared.Tap(1000, 2000)
+	if err := band.Start(48000, func(samples []float32) {}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		close(blocked)
+		band.Stop()
+		tap.Stop()
+	}()
+
+	returned := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			capture.onSamples(make([]float32, 4800))
+		}
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("capture callback blocked on a tap")
+	}
+
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tap received nothing")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,310 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gleicon/go-fsk/fsk/utils"
+)
+
+// errSourceStopped is returned by Start on a feeder that was stopped: its
+// input may be part way through a read that is never collected.
+var errSourceStopped = errors.New("source was stopped and cannot be restarted")
+
+// feeder delivers blocks produced by read from a goroutine until read
+// returns an error. It backs the sources that are not driven by a sound
+// card.
+type feeder struct {
+	read    func(block []float32) (int, error)
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	done    chan struct{}
+	err     error
+}
+
+func newFeeder(read func(block []float32) (int, error)) *feeder {
+	return &feeder{read: read, done: make(chan struct{})}
+}
+
+// Start begins delivering blocks. The sample rate is that of the input.
+// Starting a running source does nothing; starting a stopped one fails.
+func (f *feeder) Start(sampleRate int, onSamples func(samples []float32)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stopped {
+		return errSourceStopped
+	}
+	if f.started {
+		return nil
+	}
+	f.started = true
+	go f.run(onSamples)
+	return nil
+}
+
+func (f *feeder) run(onSamples func(samples []float32)) {
+	defer close(f.done)
+
+	block := make([]float32, sourceBlockSize)
+	for {
+		n, err := f.read(block)
+
+		f.mu.Lock()
+		stopped := f.stopped
+		f.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		// The callback runs unlocked, so it may call back into the source
+		if n > 0 {
+			onSamples(block[:n])
+		}
+		if err != nil {
+			if err != io.EOF {
+				f.mu.Lock()
+				f.err = err
+				f.mu.Unlock()
+			}
+			return
+		}
+	}
+}
+
+// Unpaced marks the source as delivering faster than real time.
+func (f *feeder) Unpaced() {}
+
+// Done returns a channel that is closed once the input is exhausted or
+// the source is stopped.
+func (f *feeder) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the read error that ended delivery, or nil at end of input.
+func (f *feeder) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Stop ends delivery. A read already in progress is not interrupted, but
+// its block is discarded; a block already being delivered is not recalled.
+// A stopped source cannot be started again.
+func (f *feeder) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stopped = true
+	if !f.started {
+		f.started = true
+		close(f.done)
+	}
+	return nil
+}
+
+// Close stops the source.
+func (f *feeder) Close() error {
+	return f.Stop()
+}
+
+// PCMSource reads raw little-endian 16-bit mono PCM from an io.Reader, such
+// as os.Stdin, and delivers it as fast as it can be read. The reader is not
+// closed.
+type PCMSource struct {
+	*feeder
+}
+
+// NewPCMSource creates a source reading from r.
+func NewPCMSource(r io.Reader) *PCMSource {
+	raw := make([]byte, sourceBlockSize*2)
+	read := func(block []float32) (int, error) {
+		n, err := io.ReadFull(r, raw[:len(block)*2])
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		pcm16ToFloat(block[:0], raw[:n])
+		return n / 2, err
+	}
+	return &PCMSource{newFeeder(read)}
+}
+
+// PCMSink writes raw little-endian 16-bit mono PCM to an io.Writer, such as
+// os.Stdout. The writer is not closed.
+type PCMSink struct {
+	writer io.Writer
+	raw    []byte
+	mu     sync.Mutex
+}
+
+// NewPCMSink creates a sink writing to w.
+func NewPCMSink(w io.Writer) *PCMSink {
+	return &PCMSink{writer: w}
+}
+
+// Start does nothing; the stream carries no sample rate.
+func (s *PCMSink) Start(sampleRate int) error {
+	return nil
+}
+
+// Write converts samples to PCM and writes them.
+func (s *PCMSink) Write(samples []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cap(s.raw) < len(samples)*2 {
+		s.raw = make([]byte, len(samples)*2)
+	}
+	raw := s.raw[:len(samples)*2]
+	floatToPCM16(raw, samples)
+	_, err := s.writer.Write(raw)
+	return err
+}
+
+// Drain does nothing; Write returns once the samples are written.
+func (s *PCMSink) Drain() error {
+	return nil
+}
+
+// Stop does nothing.
+func (s *PCMSink) Stop() error {
+	return nil
+}
+
+// Close does nothing.
+func (s *PCMSink) Close() error {
+	return nil
+}
+
+// WAVSource delivers the samples of a WAV file as fast as they can be
+// consumed.
+type WAVSource struct {
+	*feeder
+	samples []float32
+	format  utils.WAVFormat
+	once    sync.Once
+	err     error
+}
+
+// NewWAVSource reads filename and returns a source playing its samples,
+// with every channel mixed down to mono. A truncated file plays the samples
+// it holds, and Err then reports the truncation.
+func NewWAVSource(filename string) (*WAVSource, error) {
+	samples, format, err := utils.ReadWAVFile(filename)
+	end := io.EOF
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		end = err
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := &WAVSource{samples: samples, format: format}
+	s.feeder = newFeeder(func(block []float32) (int, error) {
+		n := copy(block, s.samples)
+		s.samples = s.samples[n:]
+		if len(s.samples) == 0 {
+			return n, end
+		}
+		return n, nil
+	})
+	return s, nil
+}
+
+// Format returns the format of the file being played.
+func (s *WAVSource) Format() utils.WAVFormat {
+	return s.format
+}
+
+// Start begins delivering the file, resampled to sampleRate if the file was
+// recorded at another rate.
+func (s *WAVSource) Start(sampleRate int, onSamples func(samples []float32)) error {
+	s.once.Do(func() {
+		if s.format.SampleRate != sampleRate {
+			s.samples, s.err = utils.Resample(s.samples, s.format.SampleRate, sampleRate)
+		}
+	})
+	if s.err != nil {
+		return s.err
+	}
+	return s.feeder.Start(sampleRate, onSamples)
+}
+
+// WAVSink records samples to a WAV file as they are written. The header
+// sizes are completed on Close.
+type WAVSink struct {
+	filename   string
+	sampleRate int
+	file       *os.File
+	writer     *utils.WAVWriter
+	mu         sync.Mutex
+}
+
+// NewWAVSink creates a sink recording to filename. The file is created by
+// the first Start.
+func NewWAVSink(filename string) *WAVSink {
+	return &WAVSink{filename: filename}
+}
+
+// Start creates the file at sampleRate. Later calls, as the transmit queue
+// restarts, continue the same recording and must use the same rate.
+func (s *WAVSink) Start(sampleRate int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer != nil {
+		if sampleRate != s.sampleRate {
+			return fmt.Errorf("%s is recording at %d Hz, not %d Hz", s.filename, s.sampleRate, sampleRate)
+		}
+		return nil
+	}
+
+	file, err := os.Create(s.filename)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.sampleRate = sampleRate
+	s.writer = utils.NewWAVWriter(file, sampleRate)
+	return nil
+}
+
+// Write appends samples to the recording.
+func (s *WAVSink) Write(samples []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer == nil {
+		return fmt.Errorf("%s: write before Start", s.filename)
+	}
+	return s.writer.Write(samples)
+}
+
+// Drain does nothing; samples are recorded as soon as they are written.
+func (s *WAVSink) Drain() error {
+	return nil
+}
+
+// Stop does nothing; the recording continues across transmissions.
+func (s *WAVSink) Stop() error {
+	return nil
+}
+
+// Close completes the file header and closes the file.
+func (s *WAVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.writer = nil
+	return err
+}
\ No newline at end of file
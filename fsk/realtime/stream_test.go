@@ -0,0 +1,139 @@
+package realtime
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+)
+
+// drain starts source and returns every sample it delivers.
+func drain(t *testing.T, source *feeder, sampleRate int) []float32 {
+	t.Helper()
+	var samples []float32
+	if err := source.Start(sampleRate, func(block []float32) {
+		samples = append(samples, block...)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-source.Done()
+	if err := source.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestPCMRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float32
+	}{
+		{"empty", nil},
+		{"extremes", []float32{0, 1, -1, 0.5, -0.5}},
+		{"clipped", []float32{2, -2}},
+		{"several blocks", make([]float32, 3*sourceBlockSize+7)},
+	}
+
+	for _, tt := range tests {
+		var pcm bytes.Buffer
+		if err := NewPCMSink(&pcm).Write(tt.samples); err != nil {
+			t.Fatal(err)
+		}
+		got := drain(t, NewPCMSource(&pcm).feeder, 48000)
+
+		if len(got) != len(tt.samples) {
+			t.Fatalf("%s: read %d samples, want %d", tt.name, len(got), len(tt.samples))
+		}
+		for i, sample := range tt.samples {
+			want := math.Max(-1, math.Min(1, float64(sample)))
+			if math.Abs(float64(got[i])-want) > 1.0/32767 {
+				t.Errorf("%s: sample %d = %v, want %v", tt.name, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestWAVSinkSource(t *testing.T) {
+	message := []byte("recorded")
+	tests := []struct {
+		name          string
+		recordRate    int
+		playRate      int
+		transmissions int
+	}{
+		{"same rate", 48000, 48000, 1},
+		{"resampled", 44100, 48000, 1},
+		{"continued recording", 48000, 48000, 2},
+	}
+
+	for _, tt := range tests {
+		config := core.DefaultConfig()
+		config.SampleRate = tt.recordRate
+		filename := filepath.Join(t.TempDir(), "recording.wav")
+
+		sink := NewWAVSink(filename)
+		var want []byte
+		for i := 0; i < tt.transmissions; i++ {
+			if err := sink.Start(tt.recordRate); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Write(core.New(config).Encode(message)); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, message...)
+		}
+		if err := sink.Start(tt.recordRate + 1); err == nil {
+			t.Errorf("%s: restarting the recording at another rate succeeded", tt.name)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		source, err := NewWAVSource(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format := source.Format(); format.SampleRate != tt.recordRate {
+			t.Errorf("%s: Format().SampleRate = %d, want %d", tt.name, format.SampleRate, tt.recordRate)
+		}
+		var samples []float32
+		if err := source.Start(tt.playRate, func(block []float32) {
+			samples = append(samples, block...)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		<-source.Done()
+
+		config.SampleRate = tt.playRate
+		if got := core.New(config).Decode(samples); !bytes.Equal(got, want) {
+			t.Errorf("%s: decoded %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestFeederStop(t *testing.T) {
+	source := newFeeder(func(block []float32) (int, error) {
+		return len(block), nil
+	})
+
+	// The callback may use the source, here to stop it after two blocks
+	blocks := 0
+	if err := source.Start(48000, func(samples []float32) {
+		blocks++
+		if source.Err() == nil && blocks == 2 {
+			source.Stop()
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-source.Done()
+
+	if blocks != 2 {
+		t.Errorf("delivered %d blocks, want 2", blocks)
+	}
+	if err := source.Start(48000, func([]float32) {}); err == nil {
+		t.Error("restarting a stopped source succeeded")
+	}
+}
\ No newline at end of file
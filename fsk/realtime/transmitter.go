@@ -1,104 +1,83 @@
 package realtime
 
 import (
-	"encoding/binary"
-	"fmt"
+	"context"
 	"sync"
 	"time"
 
-	"github.com/gen2brain/malgo"
 	"github.com/gleicon/go-fsk/fsk/core"
 )
 
-// Transmitter handles real-time audio generation and playback.
+// Transmitter handles real-time audio generation and playback. Frames are
+// queued and played in order; the sink is opened on the first send.
 type Transmitter struct {
-	modem       *core.Modem
-	ctx         *malgo.AllocatedContext
-	device      *malgo.Device
-	signal      []float32
-	sampleIndex uint32
-	mu          sync.Mutex
+	modem *core.Modem
+	queue *TransmitQueue
+	mu    sync.Mutex
 }
 
-// NewTransmitter creates a new real-time transmitter.
+// NewTransmitter creates a new real-time transmitter on the default playback
+// device.
 func NewTransmitter(modem *core.Modem) (*Transmitter, error) {
-	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
-		// Audio system messages (optional logging)
-	})
+	sink, err := NewMalgoSink()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize audio context: %v", err)
+		return nil, err
 	}
+	return NewTransmitterWithSink(modem, sink), nil
+}
 
+// NewTransmitterWithSink creates a transmitter that plays into sink.
+func NewTransmitterWithSink(modem *core.Modem, sink AudioSink) *Transmitter {
 	return &Transmitter{
 		modem: modem,
-		ctx:   ctx,
-	}, nil
+		queue: NewTransmitQueue(modem, sink),
+	}
 }
 
-// Transmit encodes and transmits data in real-time.
+// Transmit encodes data and blocks until it has been played.
 func (t *Transmitter) Transmit(data []byte) error {
-	t.mu.Lock()
-	t.signal = t.modem.Encode(data)
-	t.sampleIndex = 0
-	t.mu.Unlock()
-
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Playback)
-	deviceConfig.Playback.Format = malgo.FormatS16
-	deviceConfig.Playback.Channels = 1
-	deviceConfig.SampleRate = uint32(t.modem.Config().SampleRate)
-	deviceConfig.Alsa.NoMMap = 1
-
-	onSendFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
-		t.mu.Lock()
-		defer t.mu.Unlock()
+	return t.SendAndWait(context.Background(), data)
+}
 
-		for i := uint32(0); i < framecount; i++ {
-			var sample int16
-			if t.sampleIndex < uint32(len(t.signal)) {
-				floatSample := t.signal[t.sampleIndex]
-				if floatSample > 1.0 {
-					floatSample = 1.0
-				}
-				if floatSample < -1.0 {
-					floatSample = -1.0
-				}
-				sample = int16(floatSample * 32767)
-				t.sampleIndex++
-			} else {
-				sample = 0
-			}
+// Send queues data for transmission and returns at once.
+func (t *Transmitter) Send(data []byte) (*Transmission, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-			outputIndex := i * 2
-			if outputIndex+1 < uint32(len(pOutputSample)) {
-				binary.LittleEndian.PutUint16(pOutputSample[outputIndex:], uint16(sample))
-			}
-		}
+	if err := t.queue.Start(); err != nil {
+		return nil, err
 	}
+	return t.queue.Send(data), nil
+}
 
-	device, err := malgo.InitDevice(t.ctx.Context, deviceConfig, malgo.DeviceCallbacks{
-		Data: onSendFrames,
-	})
+// SendAndWait queues data and blocks until it has played or ctx ends.
+func (t *Transmitter) SendAndWait(ctx context.Context, data []byte) error {
+	t.mu.Lock()
+	err := t.queue.Start()
+	t.mu.Unlock()
 	if err != nil {
-		return fmt.Errorf("failed to initialize playback device: %v", err)
+		return err
 	}
-	defer device.Uninit()
+	return t.queue.SendAndWait(ctx, data)
+}
 
-	err = device.Start()
-	if err != nil {
-		return fmt.Errorf("failed to start playback device: %v", err)
-	}
-	defer device.Stop()
+// Cancel removes a queued frame that has not started playing.
+func (t *Transmitter) Cancel(id uint64) bool {
+	return t.queue.Cancel(id)
+}
 
-	transmissionTime := float64(len(t.signal)) / float64(t.modem.Config().SampleRate)
-	time.Sleep(time.Duration(transmissionTime*1000+500) * time.Millisecond)
+// SetFrameGap sets the silence left between frames.
+func (t *Transmitter) SetFrameGap(gap time.Duration) {
+	t.queue.SetFrameGap(gap)
+}
 
-	return nil
+// OnComplete sets a callback run whenever a frame completes.
+func (t *Transmitter) OnComplete(callback func(id uint64, err error)) {
+	t.queue.OnComplete(callback)
 }
 
-// Close cleans up resources.
+// Close stops transmission and cleans up resources.
 func (t *Transmitter) Close() {
-	if t.ctx != nil {
-		t.ctx.Uninit()
-		t.ctx.Free()
-	}
+	t.queue.Stop()
+	t.queue.sink.Close()
 }
\ No newline at end of file
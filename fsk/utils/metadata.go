@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+	"github.com/gleicon/go-fsk/fsk/framing"
+)
+
+// wavMetadataChunk is the ID of the RIFF chunk holding WAVMetadata. Readers
+// that do not know it skip it like any other unknown chunk.
+const wavMetadataChunk = "fsk "
+
+// wavMetadataVersion is written in every metadata chunk so that later
+// layouts can be told apart.
+const wavMetadataVersion = 1
+
+// maxMetadataSize bounds the metadata chunk a reader accepts.
+const maxMetadataSize = 64 * 1024
+
+// WAVMetadata describes how the signal in a WAV file was generated, so a
+// receiver can decode it without being told the modem parameters.
+type WAVMetadata struct {
+	Modem   core.Config
+	Framing *framing.Config // nil unless the signal carries frames
+}
+
+// wavMetadataJSON is the layout of the metadata chunk. Field names are
+// spelled out so that renaming Go fields does not break existing files.
+type wavMetadataJSON struct {
+	Version int          `json:"version"`
+	Modem   modemJSON    `json:"modem"`
+	Framing *framingJSON `json:"framing,omitempty"`
+}
+
+type modemJSON struct {
+	BaseFreq       float64   `json:"base_freq"`
+	FreqSpacing    float64   `json:"freq_spacing"`
+	Order          int       `json:"order"`
+	BaudRate       float64   `json:"baud_rate"`
+	SampleRate     int       `json:"sample_rate"`
+	Tones          []float64 `json:"tones,omitempty"`
+	Detector       int       `json:"detector"`
+	TimingRecovery bool      `json:"timing_recovery"`
+	Modulation     int       `json:"modulation"`
+	BT             float64   `json:"bt,omitempty"`
+}
+
+type framingJSON struct {
+	PreambleSymbols int    `json:"preamble_symbols"`
+	SyncWord        []byte `json:"sync_word"`
+	MaxPayload      int    `json:"max_payload"`
+}
+
+// marshal encodes the metadata as the body of its chunk.
+func (m WAVMetadata) marshal() ([]byte, error) {
+	config := m.Modem
+	body := wavMetadataJSON{
+		Version: wavMetadataVersion,
+		Modem: modemJSON{
+			BaseFreq:       config.BaseFreq,
+			FreqSpacing:    config.FreqSpacing,
+			Order:          config.Order,
+			BaudRate:       config.BaudRate,
+			SampleRate:     config.SampleRate,
+			Tones:          config.Tones,
+			Detector:       int(config.Detector),
+			TimingRecovery: config.TimingRecovery,
+			Modulation:     int(config.Modulation),
+			BT:             config.BT,
+		},
+	}
+	if m.Framing != nil {
+		body.Framing = &framingJSON{
+			PreambleSymbols: m.Framing.PreambleSymbols,
+			SyncWord:        m.Framing.SyncWord,
+			MaxPayload:      m.Framing.MaxPayload,
+		}
+	}
+	return json.Marshal(body)
+}
+
+// readMetadataChunk reads the body of a metadata chunk of size bytes and its
+// pad byte.
+func readMetadataChunk(r io.Reader, size int64) ([]byte, error) {
+	data := make([]byte, size+size%2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("reading metadata chunk: %w", err)
+	}
+	return data[:size], nil
+}
+
+// parseMetadata decodes the body of a metadata chunk.
+func parseMetadata(data []byte) (*WAVMetadata, error) {
+	var body wavMetadataJSON
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("invalid metadata chunk: %w", err)
+	}
+	if body.Version != wavMetadataVersion {
+		return nil, fmt.Errorf("metadata chunk version %d", body.Version)
+	}
+
+	modem := body.Modem
+	metadata := &WAVMetadata{
+		Modem: core.Config{
+			BaseFreq:       modem.BaseFreq,
+			FreqSpacing:    modem.FreqSpacing,
+			Order:          modem.Order,
+			BaudRate:       modem.BaudRate,
+			SampleRate:     modem.SampleRate,
+			Tones:          modem.Tones,
+			Detector:       core.DetectorType(modem.Detector),
+			TimingRecovery: modem.TimingRecovery,
+			Modulation:     core.Modulation(modem.Modulation),
+			BT:             modem.BT,
+		},
+	}
+	if body.Framing != nil {
+		metadata.Framing = &framing.Config{
+			PreambleSymbols: body.Framing.PreambleSymbols,
+			SyncWord:        body.Framing.SyncWord,
+			MaxPayload:      body.Framing.MaxPayload,
+		}
+	}
+	return metadata, nil
+}
+
+// ReadWAVMetadata returns the modem configuration embedded in a WAV file,
+// or nil if the file has none.
+func ReadWAVMetadata(filename string) (*WAVMetadata, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := NewWAVReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return reader.Metadata(), nil
+}
\ No newline at end of file
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gleicon/go-fsk/fsk/core"
+	"github.com/gleicon/go-fsk/fsk/framing"
+)
+
+func TestWAVMetadata(t *testing.T) {
+	framingConfig := framing.DefaultConfig()
+	bell103 := core.Bell103Config()
+	gfsk := core.DefaultConfig()
+	gfsk.Modulation = core.ModulationGFSK
+	gfsk.BT = 0.3
+	gfsk.Order = 1
+
+	tests := []struct {
+		name     string
+		metadata WAVMetadata
+	}{
+		{"default", WAVMetadata{Modem: core.DefaultConfig()}},
+		{"explicit tones", WAVMetadata{Modem: bell103}},
+		{"GFSK", WAVMetadata{Modem: gfsk}},
+		{"framed", WAVMetadata{Modem: core.UltrasonicConfig(), Framing: &framingConfig}},
+	}
+
+	for _, tt := range tests {
+		filename := filepath.Join(t.TempDir(), "test.wav")
+		signal := core.New(tt.metadata.Modem).Encode([]byte("metadata"))
+		if err := WriteWAVFileWithMetadata(filename, signal, tt.metadata); err != nil {
+			t.Fatal(err)
+		}
+
+		metadata, err := ReadWAVMetadata(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata == nil || !reflect.DeepEqual(*metadata, tt.metadata) {
+			t.Errorf("%s: ReadWAVMetadata = %+v, want %+v", tt.name, metadata, tt.metadata)
+		}
+
+		samples, format, err := ReadWAVFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format.SampleRate != tt.metadata.Modem.SampleRate {
+			t.Errorf("%s: sample rate %d, want %d", tt.name, format.SampleRate, tt.metadata.Modem.SampleRate)
+		}
+		if got := core.New(metadata.Modem).Decode(samples); string(got) != "metadata" {
+			t.Errorf("%s: decoded %q with the embedded configuration", tt.name, got)
+		}
+	}
+}
+
+func TestWAVMetadataPlacement(t *testing.T) {
+	metadata := WAVMetadata{Modem: core.DefaultConfig()}
+	body, err := metadata.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := fmtChunk(wavFormatPCM, 1, 48000, 16, 16)
+	data := chunk("data", make([]byte, 6))
+
+	tests := []struct {
+		name string
+		file []byte
+		want bool
+	}{
+		{"none", riff(format, data), false},
+		{"before data", riff(format, chunk(wavMetadataChunk, body), data), true},
+		{"after data", riff(format, data, chunk(wavMetadataChunk, body)), true},
+		{"after another trailing chunk", riff(format, data, chunk("LIST", []byte("x")), chunk(wavMetadataChunk, body)), true},
+		{"damaged", riff(format, chunk(wavMetadataChunk, body[:len(body)-1]), data), false},
+		{"unknown version", riff(format, chunk(wavMetadataChunk, []byte(`{"version":99}`)), data), false},
+	}
+
+	for _, tt := range tests {
+		reader, err := NewWAVReader(bytes.NewReader(tt.file))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := reader.Metadata() != nil; got != tt.want {
+			t.Errorf("%s: found metadata %v, want %v", tt.name, got, tt.want)
+		}
+		// Finding trailing metadata must leave the reader on the samples
+		if samples, err := reader.ReadAll(); len(samples) != 3 || err != nil {
+			t.Errorf("%s: ReadAll = %d samples, %v, want 3", tt.name, len(samples), err)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// Resampler filter parameters. Each output sample is a weighted sum of
+// 2*resampleZeroCrossings input samples (more when decimating), with a
+// Kaiser window giving about 80 dB of stopband rejection. The passband
+// reaches about 85% of the lower Nyquist frequency.
+const (
+	resampleZeroCrossings = 32
+	resampleKaiserBeta    = 8.0
+	resampleCutoff        = 0.92 // Of the lower Nyquist frequency
+	resampleMaxPhases     = 1024 // Larger ratios interpolate between phases
+)
+
+// Resampler converts audio between two sample rates with a polyphase
+// windowed-sinc filter. The ratio is reduced to up/down; when up is small
+// enough every output phase has its own sub-filter, otherwise the filter is
+// interpolated between resampleMaxPhases tabulated phases.
+type Resampler struct {
+	up, down int64
+	phases   int
+	taps     int       // Taps per phase, half before and half after
+	table    []float32 // (phases+1) rows of taps coefficients
+}
+
+// NewResampler creates a resampler from one sample rate to another.
+func NewResampler(from, to int) (*Resampler, error) {
+	if from <= 0 || to <= 0 {
+		return nil, fmt.Errorf("invalid resampling from %d Hz to %d Hz", from, to)
+	}
+
+	divisor := gcd(int64(from), int64(to))
+	r := &Resampler{
+		up:   int64(to) / divisor,
+		down: int64(from) / divisor,
+	}
+
+	// Below the input rate the filter is stretched to the output's
+	// Nyquist frequency, which needs proportionally more taps
+	scale := math.Min(1, float64(to)/float64(from))
+	half := int(math.Ceil(resampleZeroCrossings / scale))
+	r.taps = 2 * half
+
+	r.phases = resampleMaxPhases
+	if r.up <= resampleMaxPhases {
+		r.phases = int(r.up)
+	}
+
+	// Row p holds the kernel for an output that falls p/phases of an input
+	// sample after input base; tap j weighs input base+j-half+1
+	bandwidth := scale * resampleCutoff
+	r.table = make([]float32, (r.phases+1)*r.taps)
+	for p := 0; p <= r.phases; p++ {
+		frac := float64(p) / float64(r.phases)
+		for j := 0; j < r.taps; j++ {
+			x := float64(j-half+1) - frac
+			r.table[p*r.taps+j] = float32(bandwidth * sinc(bandwidth*x) * kaiser(x/float64(half), resampleKaiserBeta))
+		}
+	}
+	return r, nil
+}
+
+// Resample converts samples at the source rate to the target rate. The
+// output spans the same duration as the input; samples beyond either end
+// are taken as silence.
+func (r *Resampler) Resample(samples []float32) []float32 {
+	if r.up == r.down {
+		return append([]float32(nil), samples...)
+	}
+
+	length := int64(len(samples))
+	out := make([]float32, (length*r.up+r.down-1)/r.down)
+	half := int64(r.taps / 2)
+	for n := range out {
+		position := int64(n) * r.down
+		base := position / r.up
+
+		// Locate the output between two tabulated phases
+		phase := float64(position%r.up) * float64(r.phases) / float64(r.up)
+		row := int(phase)
+		weight := float32(phase - float64(row))
+		lower := r.table[row*r.taps : (row+1)*r.taps]
+		upper := r.table[(row+1)*r.taps : (row+2)*r.taps]
+
+		first := base - half + 1
+		var sum float32
+		for j := 0; j < r.taps; j++ {
+			i := first + int64(j)
+			if i < 0 || i >= length {
+				continue
+			}
+			coefficient := lower[j]
+			if weight != 0 {
+				coefficient += (upper[j] - lower[j]) * weight
+			}
+			sum += samples[i] * coefficient
+		}
+		out[n] = sum
+	}
+	return out
+}
+
+// Resample converts samples from one sample rate to another. See
+// Resampler for the filter used.
+func Resample(samples []float32, from, to int) ([]float32, error) {
+	r, err := NewResampler(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return r.Resample(samples), nil
+}
+
+// sinc is the normalized sinc function sin(πx)/(πx).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser evaluates a Kaiser window at x, from -1 to 1 across the window.
+func kaiser(x, beta float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return besselI0(beta*math.Sqrt(1-x*x)) / besselI0(beta)
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
\ No newline at end of file
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns one second of a sine at freq and amplitude 0.5.
+func sine(freq float64, sampleRate int) []float32 {
+	samples := make([]float32, sampleRate)
+	for i := range samples {
+		samples[i] = float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+	}
+	return samples
+}
+
+// rms returns the RMS level of samples, skipping the filter's edges.
+func rms(samples []float32) float64 {
+	middle := samples[len(samples)/10 : len(samples)*9/10]
+	var energy float64
+	for _, sample := range middle {
+		energy += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(energy / float64(len(middle)))
+}
+
+func TestResample(t *testing.T) {
+	tests := []struct {
+		from, to int
+		freq     float64
+	}{
+		{48000, 48000, 1000},
+		{44100, 48000, 1000},
+		{48000, 44100, 19000},
+		{48000, 8000, 3000},
+		{96000, 48000, 19000},
+		{8000, 96000, 1000},
+		{44100, 47999, 5000}, // Ratio too large for a phase per output
+	}
+
+	for _, tt := range tests {
+		got, err := Resample(sine(tt.freq, tt.from), tt.from, tt.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.to {
+			t.Errorf("%d to %d Hz: %d samples, want %d", tt.from, tt.to, len(got), tt.to)
+			continue
+		}
+
+		// The resampled tone matches one generated at the new rate
+		want := sine(tt.freq, tt.to)
+		var difference []float32
+		for i := range got {
+			difference = append(difference, got[i]-want[i])
+		}
+		if level := rms(difference); level > 0.005 {
+			t.Errorf("%d to %d Hz, %g Hz tone: error RMS %g", tt.from, tt.to, tt.freq, level)
+		}
+	}
+}
+
+func TestResampleRejectsAliases(t *testing.T) {
+	tests := []struct {
+		from, to int
+		freq     float64
+	}{
+		{48000, 8000, 5000},
+		{48000, 44100, 23000},
+		{96000, 48000, 30000},
+	}
+
+	for _, tt := range tests {
+		got, err := Resample(sine(tt.freq, tt.from), tt.from, tt.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 0.5 amplitude is 0.35 RMS; -60 dB below that is 0.00035
+		if level := rms(got); level > 0.00035 {
+			t.Errorf("%d to %d Hz, %g Hz tone above Nyquist: RMS %g", tt.from, tt.to, tt.freq, level)
+		}
+	}
+}
+
+func TestResampleInvalid(t *testing.T) {
+	for _, rates := range [][2]int{{0, 48000}, {48000, 0}, {-1, 48000}} {
+		if _, err := Resample(nil, rates[0], rates[1]); err == nil {
+			t.Errorf("Resample from %d to %d Hz succeeded", rates[0], rates[1])
+		}
+	}
+}
\ No newline at end of file
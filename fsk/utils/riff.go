@@ -0,0 +1,399 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV format tags found in the fmt chunk.
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// MixChannels selects the average of every channel in ReadWAVFileChannel
+// and DecodeWAV.
+const MixChannels = -1
+
+// Sentinel errors returned by the WAV parser, for use with errors.Is.
+var (
+	ErrNotWAV            = errors.New("not a RIFF/WAVE file")
+	ErrUnsupportedFormat = errors.New("unsupported WAV sample format")
+	ErrMissingChunk      = errors.New("missing WAV chunk")
+)
+
+// WAVFormat describes the samples of a WAV file, as read from its fmt chunk.
+type WAVFormat struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int  // Significant bits per sample
+	Float         bool // IEEE float samples rather than integers
+	blockAlign    int  // Bytes per frame of all channels
+}
+
+// wavFormatSize is the size of a WAVE_FORMAT_EXTENSIBLE fmt chunk, the
+// longest one whose fields are read. Anything after it is skipped.
+const wavFormatSize = 40
+
+// wavBufferSize is the buffer between WAVReader and WAVWriter and the
+// stream they wrap.
+const wavBufferSize = 64 * 1024
+
+// WAVReader decodes the samples of a RIFF/WAVE stream block by block.
+// Chunks other than fmt and data, such as LIST, fact or cue, are skipped.
+// Samples may be 8, 16, 24 or 32-bit integers or 32 and 64-bit floats, in
+// plain or WAVE_FORMAT_EXTENSIBLE files.
+type WAVReader struct {
+	src       io.Reader // Buffered, positioned in the data chunk
+	format    WAVFormat
+	metadata  *WAVMetadata
+	channel   int
+	remaining int64 // Bytes of the data chunk left, -1 up to end of stream
+	block     []byte
+}
+
+// NewWAVReader parses the header of a WAV stream up to its data chunk.
+// Skipped chunks are seeked over rather than read, and the chunks after the
+// samples are searched for metadata too. Samples are mixed down to mono
+// until SetChannel selects one channel.
+func NewWAVReader(r io.ReadSeeker) (*WAVReader, error) {
+	return newWAVReader(r)
+}
+
+// newWAVReader parses a WAV header from r, seeking over chunks when r is
+// an io.Seeker and reading through them otherwise.
+func newWAVReader(r io.Reader) (*WAVReader, error) {
+	// Files on pipes implement io.Seeker but fail to seek
+	seeker, seekable := r.(io.Seeker)
+	if seekable {
+		_, err := seeker.Seek(0, io.SeekCurrent)
+		seekable = err == nil
+	}
+
+	// Headers are read straight from a seekable stream so that seeking
+	// leaves no stale buffer behind
+	header := r
+	if !seekable {
+		header = bufio.NewReaderSize(r, wavBufferSize)
+	}
+	skip := func(n int64) error {
+		if seekable {
+			_, err := seeker.Seek(n, io.SeekCurrent)
+			return err
+		}
+		_, err := io.CopyN(io.Discard, header, n)
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	var riff [12]byte
+	if _, err := io.ReadFull(header, riff[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotWAV, err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, ErrNotWAV
+	}
+
+	reader := &WAVReader{channel: MixChannels}
+	haveFormat := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(header, chunk[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%w: data", ErrMissingChunk)
+			}
+			return nil, fmt.Errorf("reading chunk header: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			body := make([]byte, min(size, wavFormatSize))
+			if _, err := io.ReadFull(header, body); err != nil {
+				return nil, fmt.Errorf("reading fmt chunk: %w", err)
+			}
+			if err := skip(size + size%2 - int64(len(body))); err != nil {
+				return nil, fmt.Errorf("skipping %q chunk: %w", id, err)
+			}
+			format, err := parseFormat(body)
+			if err != nil {
+				return nil, err
+			}
+			reader.format = format
+			haveFormat = true
+		case wavMetadataChunk:
+			if size > maxMetadataSize {
+				if err := skip(size + size%2); err != nil {
+					return nil, fmt.Errorf("skipping %q chunk: %w", id, err)
+				}
+				break
+			}
+			data, err := readMetadataChunk(header, size)
+			if err != nil {
+				return nil, err
+			}
+			// Damaged metadata only costs the auto-configuration
+			reader.metadata, _ = parseMetadata(data)
+		case "data":
+			if !haveFormat {
+				return nil, fmt.Errorf("%w: fmt before data", ErrMissingChunk)
+			}
+			// Streaming encoders that never patched the header leave 0
+			// or 0xFFFFFFFF; read those to the end of the stream
+			reader.remaining = size
+			if size == 0 || size == math.MaxUint32 {
+				reader.remaining = -1
+			}
+			reader.src = header
+			if seekable {
+				if reader.metadata == nil && reader.remaining > 0 {
+					if err := reader.findTrailingMetadata(seeker); err != nil {
+						return nil, err
+					}
+				}
+				reader.src = bufio.NewReaderSize(r, wavBufferSize)
+			}
+			return reader, nil
+		default:
+			if err := skip(size + size%2); err != nil {
+				return nil, fmt.Errorf("skipping %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+// findTrailingMetadata looks for a metadata chunk after the data chunk,
+// where some editors move unknown chunks, then returns to the samples.
+func (r *WAVReader) findTrailingMetadata(seeker io.Seeker) error {
+	dataStart, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	reader := seeker.(io.Reader)
+
+	offset := r.remaining + r.remaining%2
+	for {
+		if _, err := seeker.Seek(offset, io.SeekCurrent); err != nil {
+			break
+		}
+		var chunk [8]byte
+		if _, err := io.ReadFull(reader, chunk[:]); err != nil {
+			break
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if string(chunk[0:4]) == wavMetadataChunk && size <= maxMetadataSize {
+			if data, err := readMetadataChunk(reader, size); err == nil {
+				r.metadata, _ = parseMetadata(data)
+			}
+			break
+		}
+		offset = size + size%2
+	}
+
+	_, err = seeker.Seek(dataStart, io.SeekStart)
+	return err
+}
+
+// Metadata returns the modem configuration embedded in the file, or nil if
+// it has none.
+func (r *WAVReader) Metadata() *WAVMetadata {
+	return r.metadata
+}
+
+// Format returns the format read from the fmt chunk.
+func (r *WAVReader) Format() WAVFormat {
+	return r.format
+}
+
+// SetChannel selects the channel returned by Read, counting from 0, or the
+// mix of all channels for MixChannels.
+func (r *WAVReader) SetChannel(channel int) error {
+	if channel < MixChannels || channel >= r.format.Channels {
+		return fmt.Errorf("channel %d out of range, file has %d", channel, r.format.Channels)
+	}
+	r.channel = channel
+	return nil
+}
+
+// Read decodes up to len(samples) frames into samples and returns how many
+// it decoded. It returns io.EOF at the end of the data chunk, and
+// io.ErrUnexpectedEOF, after the whole frames present, if the stream ends
+// before the size the chunk declares.
+func (r *WAVReader) Read(samples []float32) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if len(samples) == 0 {
+		return 0, nil
+	}
+
+	align := r.format.blockAlign
+	want := int64(len(samples) * align)
+	if r.remaining > 0 && want > r.remaining {
+		want = r.remaining
+	}
+	if int64(cap(r.block)) < want {
+		r.block = make([]byte, want)
+	}
+	block := r.block[:want]
+
+	got, err := io.ReadFull(r.src, block)
+	frames := got / align
+	for i := 0; i < frames; i++ {
+		samples[i] = decodeFrame(block[i*align:(i+1)*align], r.format, r.channel)
+	}
+	if r.remaining > 0 {
+		r.remaining -= int64(got)
+	}
+
+	switch {
+	case err == nil:
+		return frames, nil
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		if r.remaining < 0 {
+			// The stream was expected to end here; a partial frame is dropped
+			r.remaining = 0
+			if frames > 0 {
+				return frames, nil
+			}
+			return 0, io.EOF
+		}
+		r.remaining = 0
+		return frames, io.ErrUnexpectedEOF
+	default:
+		return frames, fmt.Errorf("reading data chunk: %w", err)
+	}
+}
+
+// ReadAll decodes the rest of the data chunk. It returns the samples read
+// so far with any error other than the end of the chunk.
+func (r *WAVReader) ReadAll() ([]float32, error) {
+	var samples []float32
+	if r.remaining > 0 {
+		samples = make([]float32, 0, r.remaining/int64(r.format.blockAlign))
+	}
+
+	block := make([]float32, 4096)
+	for {
+		n, err := r.Read(block)
+		samples = append(samples, block[:n]...)
+		if errors.Is(err, io.EOF) {
+			return samples, nil
+		}
+		if err != nil {
+			return samples, err
+		}
+	}
+}
+
+// DecodeWAV parses a RIFF/WAVE stream and returns one channel of it, or the
+// mix of all channels for MixChannels, with its format. Unlike WAVReader it
+// accepts streams that cannot seek, such as pipes.
+func DecodeWAV(r io.Reader, channel int) ([]float32, WAVFormat, error) {
+	reader, err := newWAVReader(r)
+	if err != nil {
+		return nil, WAVFormat{}, err
+	}
+	if err := reader.SetChannel(channel); err != nil {
+		return nil, reader.format, err
+	}
+	samples, err := reader.ReadAll()
+	return samples, reader.format, err
+}
+
+// parseFormat parses the first bytes of a fmt chunk, up to wavFormatSize.
+func parseFormat(body []byte) (WAVFormat, error) {
+	if len(body) < 16 {
+		return WAVFormat{}, fmt.Errorf("%w: fmt chunk of %d bytes", ErrUnsupportedFormat, len(body))
+	}
+
+	tag := binary.LittleEndian.Uint16(body[0:2])
+	format := WAVFormat{
+		Channels:      int(binary.LittleEndian.Uint16(body[2:4])),
+		SampleRate:    int(binary.LittleEndian.Uint32(body[4:8])),
+		blockAlign:    int(binary.LittleEndian.Uint16(body[12:14])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(body[14:16])),
+	}
+
+	// The extensible header moves the real tag into the sub-format GUID and
+	// may store fewer valid bits than the container holds
+	if tag == wavFormatExtensible {
+		if len(body) < wavFormatSize {
+			return format, fmt.Errorf("%w: extensible fmt chunk of %d bytes", ErrUnsupportedFormat, len(body))
+		}
+		if valid := int(binary.LittleEndian.Uint16(body[18:20])); valid > 0 {
+			format.BitsPerSample = valid
+		}
+		tag = binary.LittleEndian.Uint16(body[24:26])
+	}
+
+	if format.Channels < 1 || format.SampleRate < 1 {
+		return format, fmt.Errorf("%w: %d channels at %d Hz", ErrUnsupportedFormat, format.Channels, format.SampleRate)
+	}
+	container := format.blockAlign / format.Channels
+	if container*format.Channels != format.blockAlign || format.BitsPerSample > container*8 {
+		return format, fmt.Errorf("%w: block align %d for %d channels of %d bits",
+			ErrUnsupportedFormat, format.blockAlign, format.Channels, format.BitsPerSample)
+	}
+
+	switch tag {
+	case wavFormatPCM:
+		if container < 1 || container > 4 {
+			return format, fmt.Errorf("%w: %d-bit integer samples", ErrUnsupportedFormat, container*8)
+		}
+	case wavFormatFloat:
+		if container != 4 && container != 8 {
+			return format, fmt.Errorf("%w: %d-bit float samples", ErrUnsupportedFormat, container*8)
+		}
+		format.Float = true
+	default:
+		return format, fmt.Errorf("%w: format tag 0x%04x", ErrUnsupportedFormat, tag)
+	}
+	return format, nil
+}
+
+// decodeFrame returns one channel of a frame, or the mean of its channels.
+func decodeFrame(frame []byte, format WAVFormat, channel int) float32 {
+	width := format.blockAlign / format.Channels
+	if channel != MixChannels {
+		return decodeSample(frame[channel*width:(channel+1)*width], format.Float)
+	}
+
+	var sum float32
+	for c := 0; c < format.Channels; c++ {
+		sum += decodeSample(frame[c*width:(c+1)*width], format.Float)
+	}
+	return sum / float32(format.Channels)
+}
+
+// decodeSample converts a little-endian sample to the range -1 to 1.
+// Integer samples are scaled by their full container width, so padding
+// bits below the valid ones do not matter.
+func decodeSample(b []byte, float bool) float32 {
+	switch len(b) {
+	case 1:
+		// 8-bit samples are unsigned
+		return (float32(b[0]) - 128) / 128
+	case 2:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case 3:
+		v := int32(b[0])<<8 | int32(b[1])<<16 | int32(b[2])<<24
+		return float32(v>>8) / (1 << 23)
+	case 4:
+		bits := binary.LittleEndian.Uint32(b)
+		if float {
+			return math.Float32frombits(bits)
+		}
+		return float32(float64(int32(bits)) / (1 << 31))
+	default:
+		return float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	}
+}
\ No newline at end of file
@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+// chunk returns a RIFF chunk with its pad byte.
+func chunk(id string, body []byte) []byte {
+	c := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(body)))
+	c = append(c, body...)
+	if len(body)%2 == 1 {
+		c = append(c, 0)
+	}
+	return c
+}
+
+// riff wraps chunks in a RIFF/WAVE header.
+func riff(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return chunk("RIFF", body)
+}
+
+// fmtChunk returns a fmt chunk; a container wider than bits is written as
+// WAVE_FORMAT_EXTENSIBLE.
+func fmtChunk(tag uint16, channels, sampleRate, bits, container int) []byte {
+	align := channels * container / 8
+	body := binary.LittleEndian.AppendUint16(nil, tag)
+	if container != bits {
+		body = binary.LittleEndian.AppendUint16(nil, wavFormatExtensible)
+	}
+	body = binary.LittleEndian.AppendUint16(body, uint16(channels))
+	body = binary.LittleEndian.AppendUint32(body, uint32(sampleRate))
+	body = binary.LittleEndian.AppendUint32(body, uint32(sampleRate*align))
+	body = binary.LittleEndian.AppendUint16(body, uint16(align))
+	body = binary.LittleEndian.AppendUint16(body, uint16(container))
+	if container != bits {
+		body = binary.LittleEndian.AppendUint16(body, 22)
+		body = binary.LittleEndian.AppendUint16(body, uint16(bits))
+		body = binary.LittleEndian.AppendUint32(body, 0) // Channel mask
+		body = binary.LittleEndian.AppendUint16(body, tag)
+		body = append(body, make([]byte, 14)...) // Rest of the GUID
+	}
+	return chunk("fmt ", body)
+}
+
+// encodeSamples packs values, interleaved by channel, as container-bit
+// little-endian samples.
+func encodeSamples(values []float64, container int, float bool) []byte {
+	var data []byte
+	for _, v := range values {
+		switch {
+		case float && container == 32:
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(v)))
+		case float:
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		case container == 8:
+			data = append(data, byte(int(v*127)+128))
+		case container == 16:
+			data = binary.LittleEndian.AppendUint16(data, uint16(int16(v*32767)))
+		case container == 24:
+			s := int32(v * (1<<23 - 1))
+			data = append(data, byte(s), byte(s>>8), byte(s>>16))
+		default:
+			data = binary.LittleEndian.AppendUint32(data, uint32(int32(v*(1<<31-1))))
+		}
+	}
+	return data
+}
+
+func TestDecodeWAVFormats(t *testing.T) {
+	// Two channels: a ramp on the left, its negation on the right
+	var values []float64
+	for i := 0; i < 9; i++ {
+		v := float64(i-4) / 5
+		values = append(values, v, -v)
+	}
+
+	tests := []struct {
+		name            string
+		tag             uint16
+		bits, container int
+		tolerance       float64
+	}{
+		{"8-bit", wavFormatPCM, 8, 8, 1.0 / 64},
+		{"16-bit", wavFormatPCM, 16, 16, 1e-4},
+		{"24-bit", wavFormatPCM, 24, 24, 1e-6},
+		{"32-bit", wavFormatPCM, 32, 32, 1e-6},
+		{"24 in 32-bit extensible", wavFormatPCM, 24, 32, 1e-6},
+		{"float32", wavFormatFloat, 32, 32, 1e-7},
+		{"float64", wavFormatFloat, 64, 64, 1e-7},
+	}
+
+	for _, tt := range tests {
+		data := encodeSamples(values, tt.container, tt.tag == wavFormatFloat)
+		file := riff(
+			chunk("LIST", []byte("odd")), // Skipped, with a pad byte
+			fmtChunk(tt.tag, 2, 44100, tt.bits, tt.container),
+			chunk("data", data),
+		)
+
+		for _, channel := range []int{0, 1, MixChannels} {
+			samples, format, err := DecodeWAV(bytes.NewReader(file), channel)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if format.SampleRate != 44100 || format.Channels != 2 || format.BitsPerSample != tt.bits || format.Float != (tt.tag == wavFormatFloat) {
+				t.Errorf("%s: format %+v", tt.name, format)
+			}
+			if len(samples) != len(values)/2 {
+				t.Fatalf("%s, channel %d: %d samples, want %d", tt.name, channel, len(samples), len(values)/2)
+			}
+			for i, sample := range samples {
+				want := 0.0
+				if channel >= 0 {
+					want = values[2*i+channel]
+				}
+				if math.Abs(float64(sample)-want) > tt.tolerance {
+					t.Errorf("%s, channel %d: sample %d = %v, want %v", tt.name, channel, i, sample, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeWAVErrors(t *testing.T) {
+	format := fmtChunk(wavFormatPCM, 1, 8000, 16, 16)
+	data := chunk("data", make([]byte, 8))
+
+	tests := []struct {
+		name string
+		file []byte
+		want error
+	}{
+		{"empty", nil, ErrNotWAV},
+		{"not RIFF", append([]byte("RIFX"), riff(format, data)[4:]...), ErrNotWAV},
+		{"no data chunk", riff(format), ErrMissingChunk},
+		{"data before fmt", riff(data, format), ErrMissingChunk},
+		{"ADPCM", riff(fmtChunk(0x0002, 1, 8000, 4, 4), data), ErrUnsupportedFormat},
+		{"16-bit float", riff(fmtChunk(wavFormatFloat, 1, 8000, 16, 16), data), ErrUnsupportedFormat},
+		{"no channels", riff(fmtChunk(wavFormatPCM, 0, 8000, 16, 16), data), ErrUnsupportedFormat},
+		{"short fmt", riff(chunk("fmt ", make([]byte, 14)), data), ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := DecodeWAV(bytes.NewReader(tt.file), MixChannels); !errors.Is(err, tt.want) {
+			t.Errorf("%s: DecodeWAV error %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, _, err := DecodeWAV(bytes.NewReader(riff(format, data)), 1); err == nil {
+		t.Error("DecodeWAV of channel 1 in a mono file succeeded")
+	}
+}
+
+func TestDecodeWAVLongFormat(t *testing.T) {
+	samples := encodeSamples([]float64{0.1, 0.2, 0.3}, 16, false)
+	format := fmtChunk(wavFormatPCM, 1, 8000, 16, 16)[8:]
+
+	// A fmt chunk with trailing bytes, and one claiming nearly 4 GiB
+	padded := chunk("fmt ", append(append([]byte(nil), format...), make([]byte, 1001)...))
+	huge := append(binary.LittleEndian.AppendUint32([]byte("fmt "), math.MaxUint32-1), format...)
+
+	tests := []struct {
+		name    string
+		file    []byte
+		samples int
+	}{
+		{"trailing bytes", riff(padded, chunk("data", samples)), 3},
+		{"huge", riff(huge, chunk("data", samples)), 0},
+	}
+
+	for _, tt := range tests {
+		for _, seekable := range []bool{true, false} {
+			var r io.Reader = bytes.NewReader(tt.file)
+			if !seekable {
+				r = bytes.NewBuffer(tt.file)
+			}
+			got, _, err := DecodeWAV(r, MixChannels)
+			if tt.samples == 0 {
+				if err == nil {
+					t.Errorf("%s, seekable %v: DecodeWAV succeeded", tt.name, seekable)
+				}
+				continue
+			}
+			if err != nil || len(got) != tt.samples {
+				t.Errorf("%s, seekable %v: DecodeWAV = %d samples, %v, want %d", tt.name, seekable, len(got), err, tt.samples)
+			}
+		}
+	}
+}
+
+func TestWAVReaderDataSize(t *testing.T) {
+	format := fmtChunk(wavFormatPCM, 1, 8000, 16, 16)
+	samples := encodeSamples([]float64{0.1, 0.2, 0.3, 0.4}, 16, false)
+	// The data chunk header comes last, with the given size
+	header := func(size uint32) []byte {
+		return binary.LittleEndian.AppendUint32(append(riff(format), "data"...), size)
+	}
+
+	tests := []struct {
+		name    string
+		file    []byte
+		samples int
+		err     error
+	}{
+		{"exact", append(header(8), samples...), 4, nil},
+		{"trailing chunk", append(append(header(8), samples...), chunk("LIST", []byte("info"))...), 4, nil},
+		{"truncated", append(header(16), samples...), 4, io.ErrUnexpectedEOF},
+		{"partial frame", append(header(16), samples[:7]...), 3, io.ErrUnexpectedEOF},
+		{"unpatched zero size", append(header(0), samples...), 4, nil},
+		{"unpatched maximum size", append(header(math.MaxUint32), samples[:7]...), 3, nil},
+	}
+
+	for _, tt := range tests {
+		// Seekable readers and pipes must agree
+		for _, seekable := range []bool{true, false} {
+			var reader *WAVReader
+			var err error
+			if seekable {
+				reader, err = NewWAVReader(bytes.NewReader(tt.file))
+			} else {
+				reader, err = newWAVReader(bytes.NewBuffer(tt.file))
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+
+			got, err := reader.ReadAll()
+			if len(got) != tt.samples || !errors.Is(err, tt.err) {
+				t.Errorf("%s, seekable %v: ReadAll = %d samples, %v, want %d, %v", tt.name, seekable, len(got), err, tt.samples, tt.err)
+			}
+		}
+	}
+}
\ No newline at end of file
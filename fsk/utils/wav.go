@@ -2,62 +2,191 @@
 package utils
 
 import (
+	"bufio"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 
 	"github.com/gleicon/go-fsk/fsk/core"
 )
 
-// WriteWAVFile writes audio samples to a WAV file (16-bit PCM).
+// WriteWAVFile writes audio samples to a WAV file (16-bit PCM), embedding
+// config so that the file can be decoded without knowing it.
 func WriteWAVFile(filename string, signal []float32, config core.Config) error {
+	return WriteWAVFileWithMetadata(filename, signal, WAVMetadata{Modem: config})
+}
+
+// WriteWAVFileWithMetadata writes audio samples to a WAV file (16-bit PCM)
+// at the modem's sample rate, embedding the modem and framing parameters.
+func WriteWAVFileWithMetadata(filename string, signal []float32, metadata WAVMetadata) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
+
+	writer := NewWAVWriter(file, metadata.Modem.SampleRate)
+	err = writer.SetMetadata(metadata)
+	if err == nil {
+		err = writer.Write(signal)
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadWAVFile reads a WAV file and returns its samples, every channel
+// mixed down to mono, with the file's format.
+func ReadWAVFile(filename string) ([]float32, WAVFormat, error) {
+	return ReadWAVFileChannel(filename, MixChannels)
+}
+
+// ReadWAVFileChannel reads a single channel of a WAV file, counting from 0,
+// or the mix of all of them when channel is MixChannels. A data chunk cut
+// short, as left by an interrupted recorder, yields the whole frames it
+// holds together with an error wrapping io.ErrUnexpectedEOF.
+func ReadWAVFileChannel(filename string, channel int) ([]float32, WAVFormat, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, WAVFormat{}, err
+	}
 	defer file.Close()
 
-	// WAV header
-	header := []byte("RIFF")
-	file.Write(header)
+	reader, err := NewWAVReader(file)
+	if err != nil {
+		return nil, WAVFormat{}, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := reader.SetChannel(channel); err != nil {
+		return nil, reader.Format(), fmt.Errorf("%s: %w", filename, err)
+	}
 
-	// File size (will update later)
-	dataSize := len(signal) * 2 // 16-bit samples
-	fileSize := uint32(36 + dataSize)
-	binary.Write(file, binary.LittleEndian, fileSize)
+	samples, err := reader.ReadAll()
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return samples, reader.Format(), fmt.Errorf("%s: %w", filename, err)
+	}
+	if err != nil {
+		return nil, reader.Format(), fmt.Errorf("%s: %w", filename, err)
+	}
+	return samples, reader.Format(), nil
+}
 
-	// Format
-	file.Write([]byte("WAVE"))
-	file.Write([]byte("fmt "))
+// wavHeaderSize is the size of the RIFF, fmt and data chunk headers written
+// by WAVWriter, without metadata.
+const wavHeaderSize = 44
+
+// WAVWriter encodes samples as a 16-bit mono PCM WAV stream, buffering
+// writes to the underlying writer. The header is written with the first
+// samples and its sizes are patched on Close when the writer can seek;
+// otherwise they are left at 0xFFFFFFFF, which readers take as "up to the
+// end of the stream".
+type WAVWriter struct {
+	w          io.Writer
+	buf        *bufio.Writer
+	sampleRate int
+	seeker     io.WriteSeeker // nil unless the writer can seek, unlike a pipe
+	start      int64          // Offset of the header in seeker
+	metadata   []byte         // Body of the metadata chunk, if any
+	headerSize int64          // Bytes before the first sample
+	dataSize   int64          // Bytes of samples written
+	started    bool           // The header has been written
+	closed     bool
+	raw        []byte
+	err        error // First error, returned by every later call
+}
 
-	// Format chunk size
-	binary.Write(file, binary.LittleEndian, uint32(16))
+// NewWAVWriter creates a writer encoding samples at sampleRate into w.
+// Closing it does not close w.
+func NewWAVWriter(w io.Writer, sampleRate int) *WAVWriter {
+	return &WAVWriter{
+		w:          w,
+		buf:        bufio.NewWriterSize(w, wavBufferSize),
+		sampleRate: sampleRate,
+	}
+}
 
-	// Audio format (1 = PCM)
-	binary.Write(file, binary.LittleEndian, uint16(1))
+// SetMetadata embeds the modem and framing parameters in a chunk before
+// the samples. It must be called before the first Write.
+func (w *WAVWriter) SetMetadata(metadata WAVMetadata) error {
+	if w.started {
+		return errors.New("WAV metadata set after the header was written")
+	}
+	body, err := metadata.marshal()
+	if err != nil {
+		return err
+	}
+	w.metadata = body
+	return nil
+}
 
-	// Channels
-	binary.Write(file, binary.LittleEndian, uint16(1))
+// writeHeader writes the chunk headers with placeholder sizes.
+func (w *WAVWriter) writeHeader() error {
+	w.started = true
+	if seeker, ok := w.w.(io.WriteSeeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			w.seeker = seeker
+			w.start = start
+		}
+	}
 
-	// Sample rate
-	binary.Write(file, binary.LittleEndian, uint32(config.SampleRate))
+	header := make([]byte, 0, wavHeaderSize+8+len(w.metadata)+1)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, math.MaxUint32)
+	header = append(header, "WAVE"...)
+
+	header = append(header, "fmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, wavFormatPCM)
+	header = binary.LittleEndian.AppendUint16(header, 1)                      // Channels
+	header = binary.LittleEndian.AppendUint32(header, uint32(w.sampleRate))   // Sample rate
+	header = binary.LittleEndian.AppendUint32(header, uint32(w.sampleRate*2)) // Byte rate
+	header = binary.LittleEndian.AppendUint16(header, 2)                      // Block align
+	header = binary.LittleEndian.AppendUint16(header, 16)                     // Bits per sample
+
+	if len(w.metadata) > 0 {
+		header = append(header, wavMetadataChunk...)
+		header = binary.LittleEndian.AppendUint32(header, uint32(len(w.metadata)))
+		header = append(header, w.metadata...)
+		if len(w.metadata)%2 == 1 {
+			header = append(header, 0)
+		}
+	}
 
-	// Byte rate
-	byteRate := uint32(config.SampleRate * 2)
-	binary.Write(file, binary.LittleEndian, byteRate)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, math.MaxUint32)
+	w.headerSize = int64(len(header))
 
-	// Block align
-	binary.Write(file, binary.LittleEndian, uint16(2))
+	_, err := w.buf.Write(header)
+	return err
+}
 
-	// Bits per sample
-	binary.Write(file, binary.LittleEndian, uint16(16))
+// Write encodes samples, clamped to [-1, 1], as 16-bit integers.
+func (w *WAVWriter) Write(samples []float32) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return errors.New("write to closed WAVWriter")
+	}
+	if !w.started {
+		if w.err = w.writeHeader(); w.err != nil {
+			return w.err
+		}
+	}
 
-	// Data chunk
-	file.Write([]byte("data"))
-	binary.Write(file, binary.LittleEndian, uint32(dataSize))
+	size := int64(len(samples)) * 2
+	if w.headerSize+w.dataSize+size > math.MaxUint32 {
+		w.err = fmt.Errorf("WAV data exceeds 4 GiB after %d samples", w.dataSize/2)
+		return w.err
+	}
 
-	// Write audio data (convert float32 to int16)
-	for _, sample := range signal {
+	w.raw = w.raw[:0]
+	for _, sample := range samples {
 		// Clamp and convert to 16-bit
 		if sample > 1.0 {
 			sample = 1.0
@@ -65,45 +194,57 @@ func WriteWAVFile(filename string, signal []float32, config core.Config) error {
 		if sample < -1.0 {
 			sample = -1.0
 		}
-		intSample := int16(sample * 32767)
-		binary.Write(file, binary.LittleEndian, intSample)
+		w.raw = binary.LittleEndian.AppendUint16(w.raw, uint16(int16(sample*32767)))
 	}
-
+	if _, err := w.buf.Write(w.raw); err != nil {
+		w.err = err
+		return err
+	}
+	w.dataSize += size
 	return nil
 }
 
-// ReadWAVFile reads audio samples from a WAV file (16-bit PCM).
-func ReadWAVFile(filename string) ([]float32, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// Close flushes buffered samples and, if the underlying writer is an
+// io.WriteSeeker, patches the RIFF and data sizes and leaves it positioned
+// at the end of the stream.
+func (w *WAVWriter) Close() error {
+	if w.closed {
+		return w.err
 	}
-	defer file.Close()
-
-	// Skip WAV header (44 bytes for standard PCM)
-	header := make([]byte, 44)
-	_, err = file.Read(header)
-	if err != nil {
-		return nil, err
+	if w.err == nil && !w.started {
+		w.err = w.writeHeader()
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
 	}
 
-	// Verify it's a WAV file
-	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
-		return nil, fmt.Errorf("not a valid WAV file")
+	if w.err = w.buf.Flush(); w.err != nil {
+		return w.err
+	}
+	if w.seeker == nil {
+		return nil
 	}
 
-	// Read audio data
-	var signal []float32
-	for {
-		var sample int16
-		err := binary.Read(file, binary.LittleEndian, &sample)
-		if err != nil {
-			break // End of file
-		}
-		// Convert to float32
-		floatSample := float32(sample) / 32767.0
-		signal = append(signal, floatSample)
+	w.err = w.patch(w.start+4, uint32(w.headerSize-8+w.dataSize))
+	if w.err == nil {
+		w.err = w.patch(w.start+w.headerSize-4, uint32(w.dataSize))
+	}
+	if w.err == nil {
+		_, w.err = w.seeker.Seek(0, io.SeekEnd)
 	}
+	return w.err
+}
 
-	return signal, nil
+// patch overwrites the size field at offset.
+func (w *WAVWriter) patch(offset int64, size uint32) error {
+	if _, err := w.seeker.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("patching WAV header: %w", err)
+	}
+	var field [4]byte
+	binary.LittleEndian.PutUint32(field[:], size)
+	if _, err := w.seeker.Write(field[:]); err != nil {
+		return fmt.Errorf("patching WAV header: %w", err)
+	}
+	return nil
 }
\ No newline at end of file
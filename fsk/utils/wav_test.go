@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAVWriterRoundTrip(t *testing.T) {
+	signal := make([]float32, 1001)
+	for i := range signal {
+		signal[i] = float32(math.Sin(float64(i) / 10))
+	}
+	signal[0], signal[1] = 1.5, -1.5 // Clamped
+
+	tests := []struct {
+		name     string
+		seekable bool
+		writes   int // Writes the signal is split into
+	}{
+		{"file", true, 1},
+		{"file in pieces", true, 7},
+		{"pipe", false, 3},
+		{"empty file", true, 0},
+	}
+
+	for _, tt := range tests {
+		var want []float32
+		var encoded []byte
+		write := func(w *WAVWriter) {
+			for i := 0; i < tt.writes; i++ {
+				piece := signal[i*len(signal)/tt.writes : (i+1)*len(signal)/tt.writes]
+				if err := w.Write(piece); err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, piece...)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if tt.seekable {
+			filename := filepath.Join(t.TempDir(), "test.wav")
+			file, err := os.Create(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			write(NewWAVWriter(file, 22050))
+			file.Close()
+			if encoded, err = os.ReadFile(filename); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			var pipe bytes.Buffer
+			write(NewWAVWriter(&pipe, 22050))
+			encoded = pipe.Bytes()
+		}
+		if size := wavHeaderSize + 2*len(want); len(encoded) != size {
+			t.Errorf("%s: %d bytes written, want %d", tt.name, len(encoded), size)
+		}
+
+		got, format, err := DecodeWAV(bytes.NewReader(encoded), MixChannels)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if format.SampleRate != 22050 || format.Channels != 1 || format.BitsPerSample != 16 {
+			t.Errorf("%s: format %+v", tt.name, format)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: read %d samples, want %d", tt.name, len(got), len(want))
+		}
+		for i := range want {
+			clamped := math.Max(-1, math.Min(1, float64(want[i])))
+			if math.Abs(float64(got[i])-clamped) > 1.0/16384 {
+				t.Errorf("%s: sample %d = %v, want %v", tt.name, i, got[i], clamped)
+				break
+			}
+		}
+	}
+}
+
+func TestReadWAVFileTruncated(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "truncated.wav")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWAVWriter(file, 8000)
+	if err := w.Write(make([]float32, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// Cut the data chunk short by 40 samples and one stray byte
+	if err := os.Truncate(filename, wavHeaderSize+2*60-1); err != nil {
+		t.Fatal(err)
+	}
+
+	samples, format, err := ReadWAVFileChannel(filename, 0)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("error %v, want io.ErrUnexpectedEOF", err)
+	}
+	if len(samples) != 59 {
+		t.Errorf("read %d samples, want 59", len(samples))
+	}
+	if format.SampleRate != 8000 {
+		t.Errorf("format %+v", format)
+	}
+}
\ No newline at end of file